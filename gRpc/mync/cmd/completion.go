@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The completion scripts call the hidden `mync __complete` command with the
+// index of the word to complete followed by the words typed after mync, the
+// ones after the cursor included, and offer the lines it prints. The bash
+// script splits the line itself, as bash would break host:port apart.
+const bashCompletion = `# bash completion for mync, load it with:
+#   source <(mync completion bash)
+_mync() {
+    local line=${COMP_LINE:0:COMP_POINT} rest=${COMP_LINE:COMP_POINT}
+    local -a words after
+    read -r -a words <<< "$line"
+    if [[ -z $line || $line == *[[:space:]] ]]; then
+        words+=("")
+    fi
+    read -r -a after <<< "$rest"
+    if [[ -n $rest && $rest != [[:space:]]* ]]; then
+        words[${#words[@]}-1]+=${after[0]}
+        after=("${after[@]:1}")
+    fi
+    local IFS=$'\n'
+    COMPREPLY=($(mync __complete $((${#words[@]} - 2)) "${words[@]:1}" "${after[@]}" 2>/dev/null))
+}
+complete -o default -F _mync mync
+`
+
+const zshCompletion = `#compdef mync
+# zsh completion for mync, load it with:
+#   source <(mync completion zsh)
+_mync() {
+    local -a candidates
+    candidates=("${(@f)$(mync __complete $((CURRENT - 2)) "${(@)words[2,-1]}" 2>/dev/null)}")
+    if [[ -n ${candidates[1]} ]]; then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+if [[ $funcstack[1] == _mync ]]; then
+    _mync "$@"
+else
+    compdef _mync mync
+fi
+`
+
+const fishCompletion = `# fish completion for mync, load it with:
+#   mync completion fish | source
+function __mync_complete
+    set -l before (commandline -opc)
+    set -l current (commandline -ct)
+    set -l all (commandline -o)
+    set -l skip (math (count $before) + 1)
+    if test -n "$current"
+        set skip (math $skip + 1)
+    end
+    set -l after
+    if test $skip -le (count $all)
+        set after $all[$skip..-1]
+    end
+    mync __complete (math (count $before) - 1) $before[2..-1] $current $after 2>/dev/null
+end
+complete -c mync -a '(__mync_complete)'
+`
+
+var completionScripts = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// completionTimeout bounds the reflection calls made while completing, so
+// that an unreachable server doesn't hang the shell.
+const completionTimeout = 2 * time.Second
+
+// HandleCompletion prints the completion script for a shell.
+func HandleCompletion(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.Usage = func() {
+		var usageString = `
+completion: Shell completion for mync.
+
+completion: bash|zsh|fish`
+		fmt.Fprintln(w, usageString)
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return FlagParsingError{err}
+	}
+	if fs.NArg() != 1 {
+		return InvalidInputError{ErrInvalidShell}
+	}
+	script, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		return InvalidInputError{ErrInvalidShell}
+	}
+	fmt.Fprint(w, script)
+	return nil
+}
+
+// commandFlag is a flag of a sub-command, value is false for bool flags.
+type commandFlag struct {
+	name  string
+	value bool
+}
+
+// commandFlags returns the flags of a sub-command from the defaults it
+// prints for -h, which have a line per flag such as "  -name type".
+func commandFlags(handle func(io.Writer, []string) error) []commandFlag {
+	usage := new(bytes.Buffer)
+	handle(usage, []string{"-h"})
+	var flags []commandFlag
+	scanner := bufio.NewScanner(usage)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "  -")
+		if !ok {
+			continue
+		}
+		name, typ, _ := strings.Cut(line, " ")
+		flags = append(flags, commandFlag{name: name, value: len(typ) != 0})
+	}
+	return flags
+}
+
+// HandleComplete prints the candidates for a word of a mync command line
+// being typed, one per line. args are the index of the word followed by the
+// words of the line.
+func HandleComplete(w io.Writer, args []string) error {
+	var after []string
+	if len(args) != 0 {
+		// The words after the cursor only help to find the gRPC server.
+		cword, err := strconv.Atoi(args[0])
+		if err != nil || cword < 0 || cword >= len(args)-1 {
+			return nil
+		}
+		args, after = args[1:cword+2], args[cword+2:]
+	}
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	cur := args[len(args)-1]
+	var candidates []string
+	switch {
+	case len(args) == 1:
+		candidates = []string{"http", "grpc", "serve", "run", "completion"}
+	case args[0] == "completion":
+		if len(args) == 2 {
+			candidates = []string{"bash", "fish", "zsh"}
+		}
+	case args[0] == "http":
+		if len(args) == 2 && !strings.HasPrefix(cur, "-") {
+			candidates = []string{"replay"}
+			break
+		}
+		candidates = completeFlags(commandFlags(HandleHttp), args[1:])
+	case args[0] == "grpc":
+		candidates = completeGrpc(args[1:], after)
+	case args[0] == "serve":
+		candidates = completeFlags(commandFlags(HandleServe), args[1:])
+	case args[0] == "run":
+		candidates = completeFlags(commandFlags(HandleRun), args[1:])
+	}
+
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			fmt.Fprintln(w, c)
+		}
+	}
+	return nil
+}
+
+// completeFlags offers the flag names when a flag is being typed. Values of
+// flags and arguments are left to the shell.
+func completeFlags(flags []commandFlag, args []string) []string {
+	cur := args[len(args)-1]
+	if !strings.HasPrefix(cur, "-") || strings.Contains(cur, "=") {
+		return nil
+	}
+	if len(args) > 1 && takesValue(flags, args[len(args)-2]) {
+		return nil
+	}
+	prefix := "-"
+	if strings.HasPrefix(cur, "--") {
+		prefix = "--"
+	}
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = prefix + f.name
+	}
+	return names
+}
+
+func takesValue(flags []commandFlag, arg string) bool {
+	name := strings.TrimLeft(arg, "-")
+	if !strings.HasPrefix(arg, "-") || strings.Contains(name, "=") {
+		return false
+	}
+	for _, f := range flags {
+		if f.name == name {
+			return f.value
+		}
+	}
+	return false
+}
+
+// completeGrpc offers the flag names, and the services or methods of the
+// server for -service and -method. The server, service and profile are
+// taken from the words before and after the cursor.
+func completeGrpc(args, after []string) []string {
+	flags := commandFlags(HandleGrpc)
+	if len(args) == 1 && !strings.HasPrefix(args[0], "-") {
+		return []string{"describe", "list"}
+	}
+	if len(args) > 0 && (args[0] == "list" || args[0] == "describe") {
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		return completeFlags(flags, args)
+	}
+
+	prev := strings.TrimLeft(args[len(args)-2], "-")
+	if prev != "service" && prev != "method" {
+		return completeFlags(flags, args)
+	}
+	words := append(slices.Clone(args[:len(args)-2]), after...)
+	c, err := completionConfig(flags, words)
+	if err != nil || len(c.server) == 0 {
+		return nil
+	}
+	if prev == "method" && len(c.service) == 0 {
+		return nil
+	}
+
+	conn, err := setupGrpcConn(c)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	src := newDescriptorSource(conn)
+
+	if prev == "service" {
+		services, err := src.listServices(ctx)
+		if err != nil {
+			return nil
+		}
+		return services
+	}
+	sd, err := src.findService(ctx, c.service)
+	if err != nil {
+		return nil
+	}
+	var methods []string
+	for i := 0; i < sd.Methods().Len(); i++ {
+		methods = append(methods, string(sd.Methods().Get(i).Name()))
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// completionConfig picks the server, service, profile and TLS options out
+// of a partial grpc command line, skipping the values of other flags.
+func completionConfig(flags []commandFlag, args []string) (grpcConfig, error) {
+	var c grpcConfig
+	var profileName string
+	fs := flag.NewFlagSet("grpc", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&c.service, "service", "", "")
+	fs.StringVar(&profileName, "profile", "", "")
+	addTLSFlags(fs, &c.tls)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			// describe takes a symbol after the server.
+			if len(c.server) == 0 {
+				c.server = arg
+			}
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !hasValue && takesValue(flags, arg) && i+1 < len(args) {
+			i++
+			value, hasValue = args[i], true
+		}
+		if fs.Lookup(name) == nil {
+			continue
+		}
+		if !hasValue {
+			value = "true"
+		}
+		err := fs.Set(name, value)
+		if err != nil {
+			return c, err
+		}
+	}
+
+	if len(profileName) != 0 {
+		p, err := loadProfile(profileName)
+		if err != nil {
+			return c, err
+		}
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		err = p.apply(fs, set, nil)
+		if err != nil {
+			return c, err
+		}
+		if target, ok := p.get("target"); ok && len(c.server) == 0 {
+			c.server = target
+		}
+	}
+	return c, nil
+}
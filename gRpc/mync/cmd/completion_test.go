@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	svc "service"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+)
+
+func TestHandleCompletion(t *testing.T) {
+	for shell, marker := range map[string]string{
+		"bash": "complete -o default -F _mync mync",
+		"zsh":  "compdef _mync mync",
+		"fish": "complete -c mync -a '(__mync_complete)'",
+	} {
+		w := new(bytes.Buffer)
+		err := HandleCompletion(w, []string{shell})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(w.String(), marker) || !strings.Contains(w.String(), "mync __complete") {
+			t.Errorf("Unexpected %s completion script:\n%s", shell, w.String())
+		}
+	}
+
+	for _, args := range [][]string{{}, {"powershell"}, {"bash", "zsh"}} {
+		err := HandleCompletion(new(bytes.Buffer), args)
+		if err == nil || err.Error() != ErrInvalidShell.Error() {
+			t.Errorf("Expected ErrInvalidShell for %v, got %v", args, err)
+		}
+	}
+}
+
+func TestHandleComplete(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := grpc.NewServer()
+	defer s.Stop()
+	svc.RegisterUsersServer(s, &dummyUserService{})
+	svc.RegisterRepoServer(s, &dummyReposService{})
+	go func() {
+		s.Serve(l)
+	}()
+	server := l.Addr().String()
+	writeConfig(t, fmt.Sprintf("[local]\ntarget = %s\n", server))
+
+	tests := []struct {
+		name   string
+		args   []string
+		after  []string
+		output []string
+	}{
+		{
+			name:   "test1",
+			args:   []string{""},
+			output: []string{"http", "grpc", "serve", "run", "completion"},
+		},
+		{
+			name:   "test2",
+			args:   []string{"g"},
+			output: []string{"grpc"},
+		},
+		{
+			name:   "test3",
+			args:   []string{"completion", ""},
+			output: []string{"bash", "fish", "zsh"},
+		},
+		{
+			name:   "test4",
+			args:   []string{"http", "-re"},
+			output: []string{"-report", "-report-format", "-resolve", "-retries", "-retry-backoff", "-retry-on"},
+		},
+		{
+			name:   "test5",
+			args:   []string{"http", "-output", "-"},
+			output: nil,
+		},
+		{
+			name:   "test6",
+			args:   []string{"grpc", "--me"},
+			output: []string{"--metadata", "--method"},
+		},
+		{
+			name:   "test7",
+			args:   []string{"grpc", ""},
+			output: []string{"describe", "list"},
+		},
+		{
+			name:   "test8",
+			args:   []string{"grpc", "-service", ""},
+			after:  []string{server},
+			output: []string{"Repo", "Users"},
+		},
+		{
+			name:   "test9",
+			args:   []string{"grpc", "-pretty-print", "-service", "Users", "-method", "", server},
+			output: nil,
+		},
+		{
+			name:   "test10",
+			args:   []string{"grpc", "list", "-service=Users", "-method", "G"},
+			after:  []string{"-pretty-print", server},
+			output: []string{"GetUser"},
+		},
+		{
+			name:   "test11",
+			args:   []string{"grpc", "-profile", "local", "-service", "R"},
+			output: []string{"Repo"},
+		},
+		{
+			name:   "test12",
+			args:   []string{"grpc", "-service", ""},
+			output: nil,
+		},
+		{
+			name:   "test13",
+			args:   []string{"grpc", "describe", "-service", "U"},
+			after:  []string{"-method", "GetUser", server, "Users.GetUser"},
+			output: []string{"Users"},
+		},
+		{
+			name:   "test14",
+			args:   []string{"grpc", "-m"},
+			after:  []string{server},
+			output: []string{"-metadata", "-method"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			args := append([]string{strconv.Itoa(len(tc.args) - 1)}, tc.args...)
+			err := HandleComplete(w, append(args, tc.after...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var output []string
+			if w.Len() != 0 {
+				output = strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+			}
+			if diff := cmp.Diff(tc.output, output); diff != "" {
+				t.Errorf("Unexpected candidates (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jarCookie is a cookie stored in a cookieJar. A zero expires marks a
+// session cookie, which is kept in the file so that the session can be
+// continued by the next invocation.
+type jarCookie struct {
+	domain   string
+	hostOnly bool
+	path     string
+	secure   bool
+	httpOnly bool
+	expires  time.Time
+	name     string
+	value    string
+}
+
+func (c jarCookie) expired(now time.Time) bool {
+	return !c.expires.IsZero() && !c.expires.After(now)
+}
+
+// cookieJar is a http.CookieJar that is loaded from and saved to a file in
+// the Netscape cookies.txt format also used by curl, so it can be read and
+// edited by hand.
+type cookieJar struct {
+	mu      sync.Mutex
+	path    string
+	cookies []jarCookie
+}
+
+const cookieFileHeader = `# Netscape HTTP Cookie File
+# Written by mync http -cookie-jar. Fields are separated by tabs:
+# domain, include subdomains, path, secure, expires (unix time, 0 for session cookies), name, value
+`
+
+// loadCookieJar reads the cookies in path, a missing file is an empty jar.
+func loadCookieJar(path string) (*cookieJar, error) {
+	jar := &cookieJar{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		var httpOnly bool
+		if rest, ok := strings.CutPrefix(text, "#HttpOnly_"); ok {
+			text, httpOnly = rest, true
+		}
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) == 6 {
+			// Cookies with an empty value.
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("%w: %s line %d", ErrInvalidCookieJar, path, line)
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s line %d", ErrInvalidCookieJar, path, line)
+		}
+		c := jarCookie{
+			domain:   strings.TrimPrefix(fields[0], "."),
+			hostOnly: fields[1] != "TRUE",
+			path:     fields[2],
+			secure:   fields[3] == "TRUE",
+			httpOnly: httpOnly,
+			name:     fields[5],
+			value:    fields[6],
+		}
+		if expires != 0 {
+			c.expires = time.Unix(expires, 0)
+		}
+		jar.cookies = append(jar.cookies, c)
+	}
+	return jar, scanner.Err()
+}
+
+// save writes the cookies that have not expired to the jar file, replacing
+// it only once the new contents were written completely.
+func (j *cookieJar) save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	w.WriteString(cookieFileHeader)
+	now := time.Now()
+	for _, c := range j.cookies {
+		if c.expired(now) {
+			continue
+		}
+		var prefix string
+		if c.httpOnly {
+			prefix = "#HttpOnly_"
+		}
+		domain := c.domain
+		if !c.hostOnly {
+			domain = "." + domain
+		}
+		var expires int64
+		if !c.expires.IsZero() {
+			expires = c.expires.Unix()
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			prefix, domain, strings.ToUpper(strconv.FormatBool(!c.hostOnly)), c.path,
+			strings.ToUpper(strconv.FormatBool(c.secure)), expires, c.name, c.value)
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), j.path)
+}
+
+func hostname(u *url.URL) string {
+	return strings.ToLower(u.Hostname())
+}
+
+func domainMatch(host, domain string) bool {
+	return host == domain || (strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil)
+}
+
+// defaultPath is the directory of the request path, see RFC 6265 5.1.4.
+func defaultPath(u *url.URL) string {
+	p := u.EscapedPath()
+	i := strings.LastIndex(p, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return len(requestPath) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") ||
+		requestPath[len(cookiePath)] == '/'
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := hostname(u)
+	now := time.Now()
+	for _, hc := range cookies {
+		c := jarCookie{
+			domain:   host,
+			hostOnly: true,
+			path:     hc.Path,
+			secure:   hc.Secure,
+			httpOnly: hc.HttpOnly,
+			name:     hc.Name,
+			value:    hc.Value,
+		}
+		if domain := strings.ToLower(strings.TrimPrefix(hc.Domain, ".")); len(domain) != 0 {
+			if !domainMatch(host, domain) {
+				continue
+			}
+			// Without a public suffix list, a domain without an interior
+			// dot such as com could be a suffix shared by many sites, so it
+			// is only accepted for the host itself, see net/http/cookiejar.
+			if strings.Contains(domain, ".") {
+				c.domain, c.hostOnly = domain, net.ParseIP(host) != nil
+			} else if domain != host {
+				continue
+			}
+		}
+		if !strings.HasPrefix(c.path, "/") {
+			c.path = defaultPath(u)
+		}
+		switch {
+		case hc.MaxAge < 0:
+			c.expires = now
+		case hc.MaxAge > 0:
+			c.expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+		case !hc.Expires.IsZero():
+			c.expires = hc.Expires
+		}
+
+		j.remove(c.domain, c.path, c.name)
+		if !c.expired(now) {
+			j.cookies = append(j.cookies, c)
+		}
+	}
+}
+
+func (j *cookieJar) remove(domain, path, name string) {
+	for i, c := range j.cookies {
+		if c.domain == domain && c.path == path && c.name == name {
+			j.cookies = append(j.cookies[:i], j.cookies[i+1:]...)
+			return
+		}
+	}
+}
+
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := hostname(u)
+	now := time.Now()
+	var matches []jarCookie
+	for _, c := range j.cookies {
+		switch {
+		case c.expired(now):
+		case c.hostOnly && host != c.domain:
+		case !c.hostOnly && !domainMatch(host, c.domain):
+		case !pathMatch(u.EscapedPath(), c.path):
+		case c.secure && u.Scheme != "https":
+		default:
+			matches = append(matches, c)
+		}
+	}
+
+	// Cookies with longer paths are sent first.
+	sort.SliceStable(matches, func(a, b int) bool {
+		return len(matches[a].path) > len(matches[b].path)
+	})
+	cookies := make([]*http.Cookie, len(matches))
+	for i, c := range matches {
+		cookies[i] = &http.Cookie{Name: c.name, Value: c.value}
+	}
+	return cookies
+}
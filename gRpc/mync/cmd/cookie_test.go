@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleHttpCookieJar(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark", Path: "/", MaxAge: 3600})
+		http.Redirect(w, r, "/welcome", http.StatusFound)
+	})
+	mux.HandleFunc("/welcome", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil {
+			http.Error(w, "no session", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "welcome %s", c.Value)
+	})
+	mux.HandleFunc("/cookies", func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		for _, c := range r.Cookies() {
+			names = append(names, c.Name+"="+c.Value)
+		}
+		fmt.Fprint(w, strings.Join(names, " "))
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	jarFile := filepath.Join(t.TempDir(), "cookies.txt")
+	run := func(path string) string {
+		t.Helper()
+		w := new(bytes.Buffer)
+		err := HandleHttp(w, []string{"-cookie-jar", jarFile, ts.URL + path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.String()
+	}
+	readJar := func() string {
+		t.Helper()
+		data, err := os.ReadFile(jarFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if output := run("/login"); output != "welcome abc\n" {
+		t.Errorf("Expected the session cookie to be sent after the redirect, got: %q", output)
+	}
+	jar := readJar()
+	if !strings.Contains(jar, "#HttpOnly_127.0.0.1\tFALSE\t/\tFALSE\t0\tsession\tabc\n") {
+		t.Errorf("Expected the session cookie in the jar, got:\n%s", jar)
+	}
+	if !strings.Contains(jar, "127.0.0.1\tFALSE\t/\tFALSE\t") || !strings.Contains(jar, "\ttheme\tdark\n") {
+		t.Errorf("Expected the theme cookie in the jar, got:\n%s", jar)
+	}
+
+	if output := run("/cookies"); output != "session=abc theme=dark\n" {
+		t.Errorf("Expected the cookies to be sent by the next invocation, got: %q", output)
+	}
+
+	run("/logout")
+	jar = readJar()
+	if strings.Contains(jar, "\tsession\t") || !strings.Contains(jar, "\ttheme\t") {
+		t.Errorf("Expected only the session cookie to be removed, got:\n%s", jar)
+	}
+
+	err := os.WriteFile(jarFile, []byte("# edited by hand\n127.0.0.1\tFALSE\t/\tFALSE\t0\ttoken\txyz\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := run("/cookies"); output != "token=xyz\n" {
+		t.Errorf("Expected the hand written cookie to be sent, got: %q", output)
+	}
+
+	err = os.WriteFile(jarFile, []byte("127.0.0.1\tFALSE\t/\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = HandleHttp(new(bytes.Buffer), []string{"-cookie-jar", jarFile, ts.URL + "/cookies"})
+	if err == nil || !strings.HasPrefix(err.Error(), ErrInvalidCookieJar.Error()) {
+		t.Errorf("Expected error: %v, got: %v", ErrInvalidCookieJar, err)
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	jar := &cookieJar{}
+	set := func(rawURL string, cookies ...*http.Cookie) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jar.SetCookies(u, cookies)
+	}
+	set("https://www.example.com/app/login",
+		&http.Cookie{Name: "host", Value: "1"},
+		&http.Cookie{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		&http.Cookie{Name: "secure", Value: "3", Path: "/", Secure: true},
+		&http.Cookie{Name: "other", Value: "4", Domain: "other.com"},
+		&http.Cookie{Name: "expired", Value: "5", Expires: time.Now().Add(-time.Hour)},
+		&http.Cookie{Name: "tld", Value: "6", Domain: "com", Path: "/"},
+	)
+	set("http://localhost/", &http.Cookie{Name: "local", Value: "7", Domain: "localhost"})
+
+	tests := []struct {
+		name    string
+		url     string
+		cookies string
+	}{
+		{name: "test1", url: "https://www.example.com/app/page", cookies: "host=1 domain=2 secure=3"},
+		{name: "test2", url: "http://www.example.com/app", cookies: "host=1 domain=2"},
+		{name: "test3", url: "https://api.example.com/app/page", cookies: "domain=2"},
+		{name: "test4", url: "https://www.example.com/application", cookies: "domain=2 secure=3"},
+		{name: "test5", url: "https://other.com/", cookies: ""},
+		{name: "test6", url: "https://com/", cookies: ""},
+		{name: "test7", url: "http://localhost/", cookies: "local=7"},
+		{name: "test8", url: "http://api.localhost/", cookies: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cookies []string
+			for _, c := range jar.Cookies(u) {
+				cookies = append(cookies, c.Name+"="+c.Value)
+			}
+			if strings.Join(cookies, " ") != tc.cookies {
+				t.Errorf("Expected cookies %q, got: %q", tc.cookies, cookies)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCurl(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		settings []curlSetting
+		url      string
+		errMsg   string
+	}{
+		{
+			name:     "test1",
+			command:  "curl https://example.com/users",
+			settings: []curlSetting{{"disable-redirect", "true"}},
+			url:      "https://example.com/users",
+		},
+		{
+			name: "test2",
+			command: `curl -sSL -XPUT -H 'Authorization: Bearer a=b' \
+  -H "Content-Type: application/json" -d '{"name":"it'\''s"}' -u jane:se:cret -k https://example.com`,
+			settings: []curlSetting{
+				{"header", "Authorization=Bearer a=b"},
+				{"basicAuth", "jane=se:cret"},
+				{"insecure-skip-verify", "true"},
+				{"body", `{"name":"it's"}`},
+				{"content-type", "application/json"},
+				{"verb", "PUT"},
+			},
+			url: "https://example.com",
+		},
+		{
+			name:    "test3",
+			command: "curl --data-binary @body.json --url http://localhost/upload -i --max-time 2.5 --location",
+			settings: []curlSetting{
+				{"include", "true"},
+				{"timeout", "2.5s"},
+				{"body-file", "body.json"},
+				{"content-type", "application/x-www-form-urlencoded"},
+				{"verb", "POST"},
+			},
+			url: "http://localhost/upload",
+		},
+		{
+			name:    "test4",
+			command: "curl -d a=1 -d b=2 -F 'file=@x.txt' -F name=jane -A mync/1.0 -H 'Content-Type: text/plain' localhost",
+			settings: []curlSetting{
+				{"file", "file=@x.txt"},
+				{"form", "name=jane"},
+				{"header", "User-Agent=mync/1.0"},
+				{"body", "a=1&b=2"},
+				{"content-type", "text/plain"},
+				{"verb", "POST"},
+				{"disable-redirect", "true"},
+			},
+			url: "localhost",
+		},
+		{
+			name:     "test5",
+			command:  "curl -I -H 'Content-Type: text/plain' localhost",
+			settings: []curlSetting{{"header", "Content-Type=text/plain"}, {"verb", "HEAD"}, {"disable-redirect", "true"}},
+			url:      "localhost",
+		},
+		{
+			name:    "test6",
+			command: "wget localhost",
+			errMsg:  "invalid curl command: must start with curl",
+		},
+		{
+			name:    "test7",
+			command: "curl --limit-rate 1k localhost",
+			errMsg:  "invalid curl command: unsupported option --limit-rate",
+		},
+		{
+			name:    "test8",
+			command: "curl --data-binary @a.json -d b=1 localhost",
+			errMsg:  "invalid curl command: a data file cannot be combined with other data",
+		},
+		{
+			name:    "test9",
+			command: "curl 'localhost",
+			errMsg:  "invalid curl command: unterminated quote",
+		},
+		{
+			name:    "test10",
+			command: "curl -u jane localhost",
+			errMsg:  `invalid curl command: user "jane" must be user:password`,
+		},
+		{
+			name:    "test11",
+			command: "curl localhost -H",
+			errMsg:  "invalid curl command: -H needs a value",
+		},
+		{
+			name:    "test12",
+			command: "curl -L --proxy socks5://localhost:1080 --resolve example.com:443:127.0.0.1 --unix-socket /run/mync.sock https://example.com",
+			settings: []curlSetting{
+				{"proxy", "socks5://localhost:1080"},
+				{"resolve", "example.com:443:127.0.0.1"},
+				{"unix-socket", "/run/mync.sock"},
+			},
+			url: "https://example.com",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			settings, url, err := parseCurl(tc.command)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error: %q, got: %q", tc.errMsg, errMsg)
+			}
+			if diff := cmp.Diff(tc.settings, settings, cmp.AllowUnexported(curlSetting{})); diff != "" {
+				t.Errorf("Unexpected settings (-want +got):\n%s", diff)
+			}
+			if url != tc.url {
+				t.Errorf("Expected URL %q, got %q", tc.url, url)
+			}
+		})
+	}
+}
+
+func TestHandleHttpCurl(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var names []string
+		for name := range r.Header {
+			if strings.HasPrefix(name, "X-") || name == "Content-Type" || name == "Authorization" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		fmt.Fprintf(w, "%s %s\n", r.Method, r.URL.Path)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s: %s\n", name, r.Header.Get(name))
+		}
+		fmt.Fprint(w, string(body))
+	}))
+	defer ts.Close()
+	bodyFile := filepath.Join(t.TempDir(), "body.txt")
+	err := os.WriteFile(bodyFile, []byte("from a file"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linesFile := filepath.Join(t.TempDir(), "lines.txt")
+	err = os.WriteFile(linesFile, []byte("a=1\r\n&b=2\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+	}{
+		{
+			name: "test1",
+			args: []string{"-from-curl", fmt.Sprintf(`curl -X PATCH -H 'X-Token: a=b' -d '{"a":1}' -H 'Content-Type: application/json' %s/users`, ts.URL)},
+			output: `PATCH /users
+Content-Type: application/json
+X-Token: a=b
+{"a":1}
+`,
+		},
+		{
+			name: "test2",
+			args: []string{"-header", "X-Token=mine", "-body", "override", "-from-curl", fmt.Sprintf(`curl -H 'X-Token: a=b' -H 'X-Other: 1' -d a=1 %s/users`, ts.URL)},
+			output: `POST /users
+Content-Type: application/json
+X-Other: 1
+X-Token: mine
+override
+`,
+		},
+		{
+			name: "test3",
+			args: []string{"-from-curl", fmt.Sprintf(`curl -u jane:secret --data-binary @%s %s/upload`, bodyFile, ts.URL), ts.URL + "/other"},
+			output: `POST /other
+Authorization: Basic amFuZTpzZWNyZXQ=
+Content-Type: application/x-www-form-urlencoded
+from a file
+`,
+		},
+		{
+			name: "test4",
+			args: []string{"-from-curl", fmt.Sprintf(`curl --data @%s -d c=3 %s/form`, linesFile, ts.URL)},
+			output: `POST /form
+Content-Type: application/x-www-form-urlencoded
+a=1&b=2&c=3
+`,
+		},
+		{
+			name:   "test5",
+			args:   []string{"-from-curl", fmt.Sprintf(`curl --data-binary @%s %s/form`, linesFile, ts.URL)},
+			output: "POST /form\nContent-Type: application/x-www-form-urlencoded\na=1\r\n&b=2\n\n",
+		},
+		{
+			name:   "test6",
+			args:   []string{"-print-curl", "-from-curl", fmt.Sprintf(`curl -d @%s %s/form`, linesFile, ts.URL)},
+			output: fmt.Sprintf("curl -H 'Content-Type: application/x-www-form-urlencoded' --data-binary 'a=1&b=2' %s/form\n", ts.URL),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.output, w.String()); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandleHttpPrintCurl(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	err := os.WriteFile(bodyFile, []byte(`{"a":1}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+	}{
+		{
+			name:   "test1",
+			args:   []string{"-print-curl", "http://localhost/users"},
+			output: "curl -L http://localhost/users\n",
+		},
+		{
+			name:   "test2",
+			args:   []string{"-print-curl", "-verb", "PUT", "-body", `{"name":"it's"}`, "-header", "X-Token=a=b", "-basicAuth", "jane=secret", "-disable-redirect", "-timeout", "2s", "http://localhost/users/1"},
+			output: `curl -X PUT -H 'Content-Type: application/json' -H 'X-Token: a=b' -u jane:secret --data-binary '{"name":"it'\''s"}' --max-time 2 http://localhost/users/1` + "\n",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-print-curl", "-verb", "POST", "-body-file", bodyFile, "-insecure-skip-verify", "-retries", "2", "https://localhost/upload"},
+			output: fmt.Sprintf("curl -H 'Content-Type: application/json' --data-binary @%s -L -k --retry 2 https://localhost/upload\n", bodyFile),
+		},
+		{
+			name:   "test4",
+			args:   []string{"-print-curl", "-verb", "HEAD", "-include", "http://localhost/?q=a b"},
+			output: "curl -I -L -i 'http://localhost/?q=a b'\n",
+		},
+		{
+			name:   "test5",
+			args:   []string{"-print-curl", "-form", "name=jane", "-file", "doc=@" + bodyFile, "-verb", "POST", "http://localhost/upload"},
+			output: fmt.Sprintf("curl -F name=jane -F doc=@%s -L http://localhost/upload\n", bodyFile),
+		},
+		{
+			name:   "test6",
+			args:   []string{"-print-curl", "-proxy", "http://localhost:3128", "-resolve", "api.test:443:127.0.0.1", "https://api.test/"},
+			output: "curl -L -x http://localhost:3128 --resolve api.test:443:127.0.0.1 https://api.test/\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.output, w.String()); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+
+			// The printed command is read back into the same request.
+			again := new(bytes.Buffer)
+			err = HandleHttp(again, []string{"-print-curl", "-from-curl", strings.TrimSpace(w.String())})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(w.String(), again.String()); diff != "" {
+				t.Errorf("Unexpected output after reading the command back (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
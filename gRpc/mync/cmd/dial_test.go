@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// hostHandler responds with the host and path of the request.
+var hostHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%s%s", r.Host, r.URL.Path)
+})
+
+// proxyLog records the requests a test proxy has handled.
+type proxyLog struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (l *proxyLog) add(s string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, s)
+}
+
+func (l *proxyLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.requests...)
+}
+
+// tunnel copies between the two connections until both are done.
+func tunnel(a, b net.Conn) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(a, b)
+		close(done)
+	}()
+	io.Copy(b, a)
+	a.Close()
+	b.Close()
+	<-done
+}
+
+// startHttpProxy starts an HTTP proxy tunnelling CONNECT requests and
+// forwarding the others.
+func startHttpProxy(t *testing.T, log *proxyLog) string {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.add(r.Method + " " + r.Host)
+		if r.Method != http.MethodConnect {
+			r.RequestURI = ""
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		tunnel(conn, target)
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+// startSocksProxy starts a SOCKS5 proxy without authentication.
+func startSocksProxy(t *testing.T, log *proxyLog) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				target, err := socksConnect(conn, log)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				tunnel(conn, target)
+			}()
+		}
+	}()
+	return "socks5://" + l.Addr().String()
+}
+
+// socksConnect reads the SOCKS5 greeting and CONNECT request from conn and
+// dials the requested address.
+func socksConnect(conn net.Conn, log *proxyLog) (net.Conn, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return nil, err
+	}
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, err
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make([]byte, map[byte]int{1: 4, 4: 16}[request[3]])
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return nil, err
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return nil, err
+		}
+		host = string(name)
+	default:
+		return nil, fmt.Errorf("unknown address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	log.add("SOCKS5 " + addr)
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	_, err = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	if err != nil {
+		target.Close()
+		return nil, err
+	}
+	return target, nil
+}
+
+func TestHandleHttpProxy(t *testing.T) {
+	ts := httptest.NewServer(hostHandler)
+	defer ts.Close()
+	tlsServer := httptest.NewTLSServer(hostHandler)
+	defer tlsServer.Close()
+	host := ts.Listener.Addr().String()
+	tlsHost := tlsServer.Listener.Addr().String()
+
+	tests := []struct {
+		name     string
+		proxy    func(*testing.T, *proxyLog) string
+		args     []string
+		output   string
+		requests []string
+	}{
+		{
+			name:     "test1",
+			proxy:    startHttpProxy,
+			args:     []string{ts.URL + "/users"},
+			output:   host + "/users\n",
+			requests: []string{"GET " + host},
+		},
+		{
+			name:     "test2",
+			proxy:    startHttpProxy,
+			args:     []string{"-insecure-skip-verify", tlsServer.URL + "/users"},
+			output:   tlsHost + "/users\n",
+			requests: []string{"CONNECT " + tlsHost},
+		},
+		{
+			name:     "test3",
+			proxy:    startSocksProxy,
+			args:     []string{ts.URL + "/users"},
+			output:   host + "/users\n",
+			requests: []string{"SOCKS5 " + host},
+		},
+		{
+			name:     "test4",
+			proxy:    startSocksProxy,
+			args:     []string{"-insecure-skip-verify", tlsServer.URL + "/users"},
+			output:   tlsHost + "/users\n",
+			requests: []string{"SOCKS5 " + tlsHost},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			log := new(proxyLog)
+			proxy := tc.proxy(t, log)
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, append([]string{"-proxy", proxy}, tc.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.output, w.String()); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.requests, log.get()); diff != "" {
+				t.Errorf("Unexpected proxied requests (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandleHttpUnixSocket(t *testing.T) {
+	// Unix socket paths are limited in length, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "mync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "mync.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(hostHandler)
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	w := new(bytes.Buffer)
+	err = HandleHttp(w, []string{"-unix-socket", socket, "http://docker/v1.45/containers/json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("docker/v1.45/containers/json\n", w.String()); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandleHttpResolve(t *testing.T) {
+	ts := httptest.NewServer(hostHandler)
+	defer ts.Close()
+	_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := new(bytes.Buffer)
+	err = HandleHttp(w, []string{
+		"-resolve", "other.test:" + port + ":[::1]",
+		"-resolve", "api.mync.test:" + port + ":127.0.0.1",
+		"http://api.mync.test:" + port + "/users",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("api.mync.test:"+port+"/users\n", w.String()); diff != "" {
+		t.Errorf("Unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandleHttpRoutingInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		errMsg string
+	}{
+		{name: "test1", args: []string{"-proxy", "ftp://localhost:21"}, errMsg: `proxy must be an http, https or socks5 URL: "ftp://localhost:21"`},
+		{name: "test2", args: []string{"-proxy", "localhost:3128"}, errMsg: `proxy must be an http, https or socks5 URL: "localhost:3128"`},
+		{name: "test3", args: []string{"-resolve", "example.com:443"}, errMsg: `resolve must be host:port:addr: "example.com:443"`},
+		{name: "test4", args: []string{"-resolve", "example.com:https:127.0.0.1"}, errMsg: `resolve must be host:port:addr: "example.com:https:127.0.0.1"`},
+		{name: "test5", args: []string{"-unix-socket", "/run/mync.sock", "-proxy", "http://localhost:3128"}, errMsg: ErrInvalidUnixSocket.Error()},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := HandleHttp(new(bytes.Buffer), append(tc.args, "http://localhost"))
+			if err == nil || err.Error() != tc.errMsg {
+				t.Errorf("Expected error: %q, got: %v", tc.errMsg, err)
+			}
+		})
+	}
+}
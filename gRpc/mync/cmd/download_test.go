@@ -0,0 +1,437 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandleHttpDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+	var mu sync.Mutex
+	var ranges []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/no-range", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	})
+	mux.HandleFunc("/interrupted", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.Write(content[:100*1024])
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		partial []byte
+		ranges  []string
+		errMsg  string
+	}{
+		{name: "test1", url: "/file", ranges: []string{""}},
+		{name: "test2", url: "/file", partial: content[:100*1024], ranges: []string{"bytes=102400-"}},
+		{name: "test3", url: "/no-range", partial: []byte("stale data")},
+		{name: "test4", url: "/file", partial: content, ranges: []string{"bytes=262144-"}},
+		{name: "test5", url: "/file", partial: append(append([]byte{}, content...), "extra"...), ranges: []string{"bytes=262149-", ""}},
+		{name: "test6", url: "/missing", errMsg: "server responded with 404 Not Found"},
+	}
+
+	origStderr := stderr
+	defer func() { stderr = origStderr }()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			progress := new(bytes.Buffer)
+			stderr = progress
+			ranges = nil
+			outputFile := filepath.Join(t.TempDir(), "download.out")
+			if tc.partial != nil {
+				err := os.WriteFile(outputFile+partialSuffix, tc.partial, 0666)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, []string{"-timeout", "5s", "-output", outputFile, ts.URL + tc.url})
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if tc.ranges != nil && fmt.Sprint(ranges) != fmt.Sprint(tc.ranges) {
+				t.Errorf("Expected range requests %q, got: %q", tc.ranges, ranges)
+			}
+			if err != nil {
+				_, err := os.Stat(outputFile)
+				if !os.IsNotExist(err) {
+					t.Errorf("Expected no output file after an error, got: %v", err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, content) {
+				t.Errorf("Expected %d bytes of content, got %d bytes", len(content), len(data))
+			}
+			_, err = os.Stat(outputFile + partialSuffix)
+			if !os.IsNotExist(err) {
+				t.Errorf("Expected the partial file to be renamed, got: %v", err)
+			}
+			if w.String() != fmt.Sprintf("Data saved to: %s\n", outputFile) {
+				t.Errorf("Unexpected output: %q", w.String())
+			}
+		})
+	}
+
+	t.Run("test7", func(t *testing.T) {
+		progress := new(bytes.Buffer)
+		stderr = progress
+		ranges = nil
+		outputFile := filepath.Join(t.TempDir(), "download.out")
+
+		err := HandleHttp(new(bytes.Buffer), []string{"-timeout", "5s", "-output", outputFile, ts.URL + "/interrupted"})
+		if err == nil {
+			t.Fatal("Expected the interrupted download to fail")
+		}
+		info, err := os.Stat(outputFile + partialSuffix)
+		if err != nil || info.Size() != 100*1024 {
+			t.Fatalf("Expected a partial file of 100 KiB, got: %v %v", info, err)
+		}
+
+		err = HandleHttp(new(bytes.Buffer), []string{"-timeout", "5s", "-output", outputFile, ts.URL + "/file"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(outputFile)
+		if err != nil || !bytes.Equal(data, content) {
+			t.Errorf("Expected the resumed download to match, got %d bytes: %v", len(data), err)
+		}
+		if fmt.Sprint(ranges) != "[bytes=102400-]" {
+			t.Errorf("Expected a range request, got: %q", ranges)
+		}
+		if !strings.HasSuffix(progress.String(), "\rDownloaded 256.0 KiB of 256.0 KiB (100%)\n") {
+			t.Errorf("Unexpected progress: %q", progress.String())
+		}
+	})
+
+	// The download is only resumed if the file did not change since, the
+	// server sends all of it otherwise.
+	var version string
+	var ifRanges []string
+	mux.HandleFunc("/versioned", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+		v := version
+		mu.Unlock()
+		body := content
+		if v == "v2" {
+			body = bytes.ToUpper(content)
+		}
+		w.Header().Set("ETag", `"`+v+`"`)
+		if r.URL.Query().Has("interrupted") {
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+			w.Write(body[:100*1024])
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(body))
+	})
+	for _, tc := range []struct {
+		name    string
+		version string
+		content []byte
+		ranges  []string
+	}{
+		{name: "test8", version: "v1", content: content, ranges: []string{"bytes=102400-"}},
+		{name: "test9", version: "v2", content: bytes.ToUpper(content), ranges: []string{"bytes=102400-"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stderr = new(bytes.Buffer)
+			outputFile := filepath.Join(t.TempDir(), "download.out")
+			version = "v1"
+			err := HandleHttp(new(bytes.Buffer), []string{"-output", outputFile, ts.URL + "/versioned?interrupted"})
+			if err == nil {
+				t.Fatal("Expected the interrupted download to fail")
+			}
+
+			version, ranges, ifRanges = tc.version, nil, nil
+			err = HandleHttp(new(bytes.Buffer), []string{"-output", outputFile, ts.URL + "/versioned"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(outputFile)
+			if err != nil || !bytes.Equal(data, tc.content) {
+				t.Errorf("Expected the %s content, got %d bytes: %v", tc.version, len(data), err)
+			}
+			if fmt.Sprint(ranges) != fmt.Sprint(tc.ranges) || fmt.Sprint(ifRanges) != `["v1"]` {
+				t.Errorf("Expected a range request if the file is unchanged, got: %q %q", ranges, ifRanges)
+			}
+			_, err = os.Stat(outputFile + partialSuffix + validatorSuffix)
+			if !os.IsNotExist(err) {
+				t.Errorf("Expected the validator to be removed, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestHandleHttpDownloadTimeout(t *testing.T) {
+	chunk := bytes.Repeat([]byte("0123456789abcdef"), 4*1024)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(10*len(chunk)))
+		for i := 0; i < 10; i++ {
+			w.Write(chunk)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+	mux.HandleFunc("/late", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		args   []string
+		errMsg string
+	}{
+		{name: "test1", args: []string{ts.URL + "/slow"}},
+		{name: "test2", args: []string{"-retries", "1", ts.URL + "/slow"}},
+		{name: "test3", args: []string{ts.URL + "/late"}, errMsg: "context deadline exceeded awaiting the response headers"},
+		{name: "test4", args: []string{"-retries", "1", "-retry-backoff", "1ms", ts.URL + "/late"}, errMsg: fmt.Sprintf("Get %q: context deadline exceeded awaiting the response headers", ts.URL+"/late")},
+	}
+	origStderr := stderr
+	defer func() { stderr = origStderr }()
+	stderr = new(bytes.Buffer)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			outputFile := filepath.Join(t.TempDir(), "download.out")
+			// The default -timeout is shorter than the download.
+			err := HandleHttp(new(bytes.Buffer), append([]string{"-output", outputFile}, tc.args...))
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if err != nil {
+				return
+			}
+			info, err := os.Stat(outputFile)
+			if err != nil || info.Size() != int64(10*len(chunk)) {
+				t.Errorf("Expected %d bytes downloaded, got: %v %v", 10*len(chunk), info, err)
+			}
+		})
+	}
+}
+
+func TestHandleHttpParallelDownload(t *testing.T) {
+	content := make([]byte, 1<<20+3)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+
+	var mu sync.Mutex
+	var requests []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/no-range", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		w.Write(content)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Range"), "bytes=0-") {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/broken-last", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.Header.Get("Range"), fmt.Sprintf("-%d", len(content)-1)) {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodGet {
+			// Only answer once the failed range canceled the others.
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		requests []string
+		errMsg   string
+	}{
+		{
+			name: "test1",
+			args: []string{"-parallel", "4", "-sha256", sum, ts.URL + "/file"},
+			requests: []string{
+				"GET bytes=0-262143",
+				"GET bytes=262144-524287",
+				"GET bytes=524288-786431",
+				"GET bytes=786432-1048578",
+				"HEAD ",
+			},
+		},
+		{
+			name:     "test2",
+			args:     []string{"-parallel", "4", ts.URL + "/no-range"},
+			requests: []string{"GET ", "HEAD "},
+		},
+		{
+			name:     "test3",
+			args:     []string{"-sha256", strings.ToUpper(sum), ts.URL + "/file"},
+			requests: []string{"GET "},
+		},
+		{
+			name:   "test4",
+			args:   []string{"-parallel", "3", "-sha256", strings.Repeat("0", 64), ts.URL + "/file"},
+			errMsg: ErrChecksumMismatch.Error() + ": expected " + strings.Repeat("0", 64) + ", got " + sum,
+		},
+		{
+			name:   "test5",
+			args:   []string{"-parallel", "3", ts.URL + "/broken"},
+			errMsg: "server responded with 500 Internal Server Error",
+		},
+		{
+			name:   "test6",
+			args:   []string{"-parallel", "0", ts.URL + "/file"},
+			errMsg: ErrInvalidParallel.Error(),
+		},
+		{
+			name:   "test7",
+			args:   []string{"-sha256", "abc", ts.URL + "/file"},
+			errMsg: ErrInvalidSha256.Error(),
+		},
+		{
+			name:   "test8",
+			args:   []string{"-parallel", "3", ts.URL + "/broken-last"},
+			errMsg: "server responded with 500 Internal Server Error",
+		},
+	}
+
+	origStderr := stderr
+	defer func() { stderr = origStderr }()
+	stderr = new(bytes.Buffer)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requests = nil
+			outputFile := filepath.Join(t.TempDir(), "download.out")
+			args := append([]string{"-timeout", "5s", "-output", outputFile}, tc.args...)
+			err := HandleHttp(new(bytes.Buffer), args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if err != nil {
+				for _, path := range []string{outputFile, outputFile + partialSuffix} {
+					_, err := os.Stat(path)
+					if !os.IsNotExist(err) {
+						t.Errorf("Expected %s to be removed, got: %v", path, err)
+					}
+				}
+				return
+			}
+
+			sort.Strings(requests)
+			if diff := cmp.Diff(tc.requests, requests); diff != "" {
+				t.Errorf("Requests mismatch (-want +got):\n%s", diff)
+			}
+			data, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, content) {
+				t.Errorf("Expected %d bytes of content, got %d bytes", len(content), len(data))
+			}
+		})
+	}
+
+	w := new(bytes.Buffer)
+	err := HandleHttp(w, []string{"-parallel", "2", ts.URL + "/file"})
+	if err == nil || err.Error() != ErrInvalidParallel.Error() {
+		t.Errorf("Expected error: %v, got: %v", ErrInvalidParallel, err)
+	}
+}
+
+func TestSplitRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int64
+		n      int
+		ranges []byteRange
+	}{
+		{name: "test1", size: 10, n: 3, ranges: []byteRange{{0, 2}, {3, 5}, {6, 9}}},
+		{name: "test2", size: 2, n: 4, ranges: []byteRange{{0, 0}, {1, 1}}},
+		{name: "test3", size: 100, n: 1, ranges: []byteRange{{0, 99}}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges := splitRanges(tc.size, tc.n)
+			if diff := cmp.Diff(tc.ranges, ranges, cmp.AllowUnexported(byteRange{})); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
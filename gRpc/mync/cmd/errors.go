@@ -1,13 +1,73 @@
 package cmd
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var ErrNoServerSpecified = errors.New("you have to specify the remote server")
 var ErrInvalidHTTPMethod = errors.New("invalid HTTP method")
 var ErrInvalidGrpcMethod = errors.New("Invalid gRPC method")
+var ErrUnrecognizedService = errors.New("unrecognized service")
+var ErrInvalidGrpcRequest = errors.New("cannot specify both request and request-file")
+var ErrInvalidGrpcRequestFile = errors.New("request-file can only be used with client or bidi streaming methods in bench mode")
+var ErrInvalidBatchConcurrency = errors.New("concurrency can only be used with bench or a request-file for a unary method")
+var ErrInvalidBatchQuery = errors.New("query cannot be used with a request-file for a unary method")
+var ErrBatchFailed = errors.New("batch failed")
+var ErrInvalidMetadata = errors.New("metadata must be key=value")
+var ErrInvalidDeadline = errors.New("deadline cannot be negative")
+var ErrInvalidErrorFormat = errors.New("error-format must be text or json")
 
 var ErrInvalidHTTPCommand = errors.New("invalid HTTP command")
-var ErrInvalidHTTPPostCommand = errors.New("cannot specify both body and body-file")
+var ErrInvalidShell = errors.New("specify one of bash, zsh or fish")
+var ErrInvalidHTTPPostCommand = errors.New("only one of body, body-file, data-urlencode and form or file can be specified")
+var ErrInvalidContentType = errors.New("content-type can only be used with a request body")
+var ErrInvalidFormField = errors.New("form fields must be key=value")
+var ErrInvalidFilePart = errors.New("file parts must be field=@path")
+var ErrInvalidMultipartContentType = errors.New("content-type cannot be used with form or file")
+var ErrInvalidTimeout = errors.New("timeout must be positive")
+var ErrInvalidBenchCommand = errors.New("load test options can only be used with bench")
+var ErrInvalidBenchConcurrency = errors.New("concurrency must be at least 1")
+var ErrInvalidBenchLimit = errors.New("duration and rate cannot be negative")
+var ErrInvalidBenchFormat = errors.New("bench-format must be text or json")
+var ErrInvalidBenchOutput = errors.New("cannot specify output in bench mode")
+var ErrInvalidBenchConnections = errors.New("connections and num-calls must be at least 1")
+var ErrInvalidBenchHar = errors.New("cannot specify har in bench mode")
+var ErrInvalidBenchPrintCurl = errors.New("cannot specify print-curl in bench mode")
+var ErrInvalidBenchMode = errors.New("bench cannot be used with list or describe")
+
+var ErrInvalidReportFormat = errors.New("report-format must be text or json")
+var ErrInvalidWriteOut = errors.New("invalid write-out template")
+var ErrInvalidQuery = errors.New("invalid query")
+var ErrQueryNoMatch = errors.New("no match for query")
+var ErrQueryNotJson = errors.New("response is not JSON")
+var ErrInvalidContentRange = errors.New("server sent an unexpected content range")
+var ErrInvalidParallel = errors.New("parallel must be at least 1 and can only be used with GET and output")
+var ErrInvalidSha256 = errors.New("sha256 must be a hex SHA-256 digest and can only be used with output")
+var ErrChecksumMismatch = errors.New("SHA-256 digest mismatch")
+var ErrInvalidCookieJar = errors.New("invalid cookie jar")
+var ErrInvalidConfig = errors.New("invalid config file")
+var ErrUnknownProfile = errors.New("unknown profile")
+var ErrInvalidRetries = errors.New("retries and retry-backoff cannot be negative")
+var ErrInvalidRetryOn = errors.New("retry-on must list status codes, classes such as 5xx or connection")
+var ErrNoHarFile = errors.New("you have to specify the HAR file to replay")
+var ErrInvalidReplay = errors.New("replay cannot be used with bench, output, har or print-curl")
+var ErrInvalidProxy = errors.New("proxy must be an http, https or socks5 URL")
+var ErrInvalidResolve = errors.New("resolve must be host:port:addr")
+var ErrInvalidUnixSocket = errors.New("unix-socket cannot be used with proxy or resolve")
+var ErrInvalidCurl = errors.New("invalid curl command")
+var ErrInvalidHar = errors.New("invalid HAR file")
+var ErrReplayMismatch = errors.New("responses differ from the recorded ones")
+var ErrNoStubs = errors.New("you have to specify the stubs file")
+var ErrInvalidStubs = errors.New("invalid stubs file")
+var ErrInvalidProtoset = errors.New("invalid protoset")
+var ErrNoScenario = errors.New("you have to specify a scenario file")
+var ErrInvalidScenario = errors.New("invalid scenario file")
+var ErrInvalidRunFormat = errors.New("format must be tap or junit")
+var ErrInvalidVar = errors.New("vars must be key=value")
+var ErrScenarioFailed = errors.New("scenario failed")
+var ErrInvalidTLSKeyPair = errors.New("cert and key must be specified together")
+
 var ErrInvalidHTTPPostRequest = errors.New("http POST request must specify a non-empty JSON body")
 
 type FlagParsingError struct {
@@ -25,3 +85,14 @@ type InvalidInputError struct {
 func (e InvalidInputError) Error() string {
 	return e.Err.Error()
 }
+
+// HTTPStatusError is returned with -fail when the server responds with a
+// 4xx or 5xx status.
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e HTTPStatusError) Error() string {
+	return fmt.Sprintf("server responded with %s", e.Status)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// batchResult is the line printed for each request of a batch.
+type batchResult struct {
+	Index    int             `json:"index"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    *batchError     `json:"error,omitempty"`
+}
+
+// batchError describes a failed call, or a request that could not be
+// parsed, in which case there is no code.
+type batchError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+type batchRequest struct {
+	index int
+	line  int
+	data  string
+}
+
+// invokeBatch calls a unary method once for every line of -request-file,
+// from -concurrency workers sharing conn. The results are printed as one
+// line of JSON per request, in the order of the requests.
+func invokeBatch(ctx context.Context, conn grpc.ClientConnInterface, src *descriptorSource, md protoreflect.MethodDescriptor, c grpcConfig, w io.Writer) error {
+	r, err := openRequestFile(c.requestFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	requests := make(chan batchRequest)
+	var readErr error
+	go func() {
+		defer close(requests)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxRequestLineSize)
+		index := 0
+		for line := 1; scanner.Scan(); line++ {
+			data := strings.TrimSpace(scanner.Text())
+			if len(data) == 0 {
+				continue
+			}
+			select {
+			case requests <- batchRequest{index: index, line: line, data: data}:
+			case <-ctx.Done():
+				return
+			}
+			index++
+		}
+		readErr = scanner.Err()
+	}()
+
+	results := make(chan batchResult)
+	var wg sync.WaitGroup
+	for i := 0; i < max(c.bench.load.concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range requests {
+				select {
+				case results <- callBatchRequest(ctx, conn, src, md, c, req):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results arriving early wait in pending until those before them
+	// were printed.
+	pending := map[int]batchResult{}
+	var next, failed int
+	for res := range results {
+		pending[res.Index] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if res.Error != nil {
+				failed++
+			}
+			line, err := json.Marshal(res)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(w, string(line))
+		}
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if failed != 0 {
+		return fmt.Errorf("%w: %d of %d calls failed", ErrBatchFailed, failed, next)
+	}
+	return nil
+}
+
+func callBatchRequest(ctx context.Context, conn grpc.ClientConnInterface, src *descriptorSource, md protoreflect.MethodDescriptor, c grpcConfig, req batchRequest) batchResult {
+	ctx, cancel := callContext(ctx, c)
+	defer cancel()
+
+	c.request = req.data
+	respJson, err := invokeUnary(ctx, conn, src, md, c)
+	if err == nil {
+		return batchResult{Index: req.index, Response: respJson}
+	}
+	if _, ok := err.(InvalidInputError); ok {
+		return batchResult{Index: req.index, Error: &batchError{
+			Message: fmt.Sprintf("request on line %d: %v", req.line, err),
+		}}
+	}
+	st := status.Convert(err)
+	return batchResult{Index: req.index, Error: &batchError{Code: st.Code().String(), Message: st.Message()}}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// benchRequests builds the messages every bench call sends up front, so
+// that a request file or stdin is only read once.
+func benchRequests(src *descriptorSource, md protoreflect.MethodDescriptor, c grpcConfig) ([]proto.Message, error) {
+	if !md.IsStreamingClient() || len(c.request) != 0 {
+		req, err := createRequest(src, md, c.request)
+		if err != nil {
+			return nil, InvalidInputError{Err: err}
+		}
+		return []proto.Message{req}, nil
+	}
+
+	r, err := openRequestFile(c.requestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var reqs []proto.Message
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		data := strings.TrimSpace(scanner.Text())
+		if len(data) == 0 {
+			continue
+		}
+		req, err := createRequest(src, md, data)
+		if err != nil {
+			return nil, InvalidInputError{Err: fmt.Errorf("request on line %d: %w", line, err)}
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, scanner.Err()
+}
+
+// benchGrpc calls c.method according to the load options, spreading the
+// workers over conns, and writes a summary of the status codes, errors and
+// latencies. Streaming methods also report messages per second and the time
+// to the first response message.
+func benchGrpc(ctx context.Context, conns []grpc.ClientConnInterface, c grpcConfig, w io.Writer) error {
+	if len(c.method) == 0 {
+		return ErrInvalidGrpcMethod
+	}
+	src := newDescriptorSource(conns[0])
+	md, err := src.findMethod(ctx, c.service, c.method)
+	if err != nil {
+		return err
+	}
+	streaming := md.IsStreamingClient() || md.IsStreamingServer()
+	if !streaming && len(c.requestFile) != 0 {
+		return ErrInvalidGrpcRequestFile
+	}
+	reqs, err := benchRequests(src, md, c)
+	if err != nil {
+		return err
+	}
+
+	stats := newLoadStats()
+	runLoad(c.bench.load, func(worker int) {
+		conn := conns[worker%len(conns)]
+		ctx, cancel := callContext(ctx, c)
+		defer cancel()
+		start := time.Now()
+		if !streaming {
+			resp := dynamicpb.NewMessage(md.Output())
+			err := conn.Invoke(ctx, methodPath(md), reqs[0], resp)
+			stats.record(time.Since(start), status.Code(err).String(), err)
+			return
+		}
+		messages, first, err := benchStream(ctx, conn, md, reqs)
+		stats.record(time.Since(start), status.Code(err).String(), err)
+		stats.recordStream(messages, first)
+	})
+	stats.stop()
+	return writeLoadSummary(w, stats.summary(), c.bench.format)
+}
+
+// benchStream makes a single streaming call and returns the number of
+// messages received and how long the first one took to arrive.
+func benchStream(ctx context.Context, conn grpc.ClientConnInterface, md protoreflect.MethodDescriptor, reqs []proto.Message) (int, time.Duration, error) {
+	desc := &grpc.StreamDesc{
+		StreamName:    string(md.Name()),
+		ServerStreams: md.IsStreamingServer(),
+		ClientStreams: md.IsStreamingClient(),
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	stream, err := conn.NewStream(ctx, desc, methodPath(md))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	go func() {
+		for _, req := range reqs {
+			if stream.SendMsg(req) != nil {
+				// RecvMsg reports why the call ended.
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	var messages int
+	var first time.Duration
+	for {
+		err = stream.RecvMsg(dynamicpb.NewMessage(md.Output()))
+		if err != nil {
+			break
+		}
+		if messages == 0 {
+			first = time.Since(start)
+		}
+		messages++
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return messages, first, err
+}
@@ -2,182 +2,236 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"io"
-	svc "service"
+	"maps"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/grpc/metadata"
 )
 
 type grpcConfig struct {
 	server      string
 	method      string
 	request     string
+	requestFile string
 	service     string
 	prettyPrint bool
+	deadline    time.Duration
+	metadata    []string
+	verbose     bool
+	query       *jsonQuery
+	tls         tlsOptions
+	bench       benchOptions
+	connections int
+	numCalls    int
+	// emitUnpopulated prints fields with default values in responses.
+	emitUnpopulated bool
 }
 
-func setupGrpcConn(addr string) (*grpc.ClientConn, error) {
-	return grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
-}
-
-func getUserServiceClient(conn *grpc.ClientConn) svc.UsersClient {
-	return svc.NewUsersClient(conn)
-}
-
-func getUser(client svc.UsersClient, u *svc.UserGetRequest) (*svc.UserGetReply, error) {
-	return client.GetUser(context.Background(), u)
-}
-
-func createUserRequest(jsonQuery string) (*svc.UserGetRequest, error) {
-	u := svc.UserGetRequest{}
-	input := []byte(jsonQuery)
-	err := protojson.Unmarshal(input, &u)
-	return &u, err
-}
-
-func getUserResponseJson(c grpcConfig, resp *svc.UserGetReply) ([]byte, error) {
-	if c.prettyPrint {
-		return []byte(protojson.Format(resp)), nil
-	}
-	return protojson.Marshal(resp)
-}
-
-func getRepoServiceClient(conn *grpc.ClientConn) svc.RepoClient {
-	return svc.NewRepoClient(conn)
-}
-
-func createGetRepoRequest(jsonQuery string) (*svc.RepoGetRequest, error) {
-	u := svc.RepoGetRequest{}
-	input := []byte(jsonQuery)
-	return &u, protojson.Unmarshal(input, &u)
-}
-
-func getRepos(client svc.RepoClient, r *svc.RepoGetRequest) (*svc.RepoGetReply, error) {
-	return client.GetRepos(context.Background(), r)
-}
-
-func getReposResponseJson(c grpcConfig, result *svc.RepoGetReply) ([]byte, error) {
-	if c.prettyPrint {
-		return []byte(protojson.Format(result)), nil
-	}
-	return protojson.Marshal(result)
-}
-
-func callUserMethod(usersClient svc.UsersClient, c grpcConfig) ([]byte, error) {
-	switch c.method {
-	case "GetUser":
-		req, err := createUserRequest(c.request)
-		if err != nil {
-			return nil, InvalidInputError{Err: err}
-		}
-		result, err := getUser(usersClient, req)
+func setupGrpcConn(c grpcConfig) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if c.tls.enabled() {
+		cfg, err := c.tls.config()
 		if err != nil {
 			return nil, err
 		}
-		respData, err := getUserResponseJson(c, result)
-		return respData, err
-	case "":
-		return nil, ErrInvalidGrpcMethod
-	default:
-		return nil, ErrInvalidGrpcMethod
-	}
-}
-
-func callRepoMethod(repoClient svc.RepoClient, c grpcConfig) ([]byte, error) {
-	switch c.method {
-	case "GetRepos":
-		req, err := createGetRepoRequest(c.request)
-		if err != nil {
-			return nil, InvalidInputError{Err: err}
-		}
-		result, err := getRepos(repoClient, req)
-		if err != nil {
-			return nil, err
-		}
-		respData, err := getReposResponseJson(c, result)
-		return respData, err
-	case "":
-		return nil, ErrInvalidGrpcMethod
-	default:
-		return nil, ErrInvalidGrpcMethod
+		creds = credentials.NewTLS(cfg)
 	}
+	return grpc.NewClient(c.server, grpc.WithTransportCredentials(creds))
 }
 
 func validateGrpcConfig(c grpcConfig) error {
 	if len(c.service) == 0 {
-		return errors.New("unrecognized service")
+		return ErrUnrecognizedService
 	}
 	if len(c.method) == 0 {
 		return ErrInvalidGrpcMethod
 	}
-
+	if len(c.request) != 0 && len(c.requestFile) != 0 {
+		return ErrInvalidGrpcRequest
+	}
 	return nil
 }
 
 func HandleGrpc(w io.Writer, args []string) error {
-	c := grpcConfig{}
+	return handleGrpc(w, args, false)
+}
+
+// handleGrpc is HandleGrpc also printing the fields of the responses that
+// have default values if emitUnpopulated is set.
+func handleGrpc(w io.Writer, args []string, emitUnpopulated bool) error {
+	var mode string
+	var queryText string
+	var profileName string
+	var errorFormat string
+	c := grpcConfig{emitUnpopulated: emitUnpopulated}
+	if len(args) > 0 && (args[0] == "list" || args[0] == "describe") {
+		mode, args = args[0], args[1:]
+	}
+
 	fs := flag.NewFlagSet("grpc", flag.ContinueOnError)
 	fs.SetOutput(w)
 	fs.StringVar(&c.method, "method", "", "Method to call")
 	fs.StringVar(&c.request, "request", "", "Request to send")
+	fs.StringVar(&c.requestFile, "request-file", "", "File with one JSON request per line (- for stdin), sent as a stream or as one unary call each")
 	fs.StringVar(&c.service, "service", "", "gRpc service to send the request to")
 	fs.BoolVar(&c.prettyPrint, "pretty-print", false, "Pretty print the JSON output")
+	fs.StringVar(&profileName, "profile", "", "Named profile in the config file to take defaults from, overridden by flags")
+	fs.StringVar(&queryText, "query", "", "Print the values selected from each JSON response, e.g. .repo[0].owner.id")
+	fs.DurationVar(&c.deadline, "deadline", 0, "Deadline of each call, e.g. 5s (no deadline by default)")
+	fs.StringVar(&errorFormat, "error-format", "text", "Format of errors with a gRPC status (text or json)")
+	fs.BoolVar(&c.verbose, "verbose", false, "Print the request metadata, response headers and trailers and the status on stderr")
+	fs.Func("metadata", "Add one or more metadata entries to the call (key=value, base64 values for -bin keys)", func(s string) error {
+		c.metadata = append(c.metadata, s)
+		return nil
+	})
+	addTLSFlags(fs, &c.tls)
+	addBenchFlags(fs, &c.bench, "calls")
+	fs.Lookup("concurrency").Usage = "Number of concurrent calls in bench mode or for unary methods with -request-file"
+	fs.IntVar(&c.connections, "connections", 1, "Number of connections to spread the calls over in bench mode")
+	fs.IntVar(&c.numCalls, "num-calls", 1, "Number of calls to make in bench mode")
 
 	fs.Usage = func() {
 		var usageString = `
 grpc: A gRPC client.
  
-grpc: <options> server`
+grpc: <options> server
+grpc: list <options> server
+grpc: describe <options> server [symbol]`
 		fmt.Fprint(w, usageString)
 		fmt.Fprintln(w)
 		fmt.Fprintln(w)
 		fmt.Fprintln(w, "Options:")
 		fs.PrintDefaults()
+		fmt.Fprint(w, exitCodesUsage)
 	}
 
 	err := fs.Parse(args)
 	if err != nil {
 		return err
 	}
-	if fs.NArg() != 1 {
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var target string
+	if len(profileName) != 0 {
+		p, err := loadProfile(profileName)
+		if err != nil {
+			return err
+		}
+		err = p.apply(fs, set, map[string]*[]string{"metadata": &c.metadata})
+		if err != nil {
+			return err
+		}
+		target, _ = p.get("target")
+	}
+
+	symbol := fs.Arg(1)
+	switch {
+	case fs.NArg() == 0 && len(target) != 0:
+		c.server = target
+	case mode == "describe" && fs.NArg() == 1 && len(target) != 0:
+		// The profile's target is described, the argument is the symbol.
+		c.server, symbol = target, fs.Arg(0)
+	case fs.NArg() == 1 || (mode == "describe" && fs.NArg() == 2):
+		c.server = fs.Arg(0)
+	default:
 		return ErrNoServerSpecified
 	}
-	c.server = fs.Arg(0)
 
-	err = validateGrpcConfig(c)
+	if c.deadline < 0 {
+		return ErrInvalidDeadline
+	}
+	if errorFormat != "text" && errorFormat != "json" {
+		return ErrInvalidErrorFormat
+	}
+	c.query, err = parseQuery(queryText)
 	if err != nil {
 		return err
 	}
-
-	conn, err := setupGrpcConn(c.server)
+	md, err := parseMetadata(c.metadata)
 	if err != nil {
 		return err
 	}
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
 
-	var usersClient svc.UsersClient
-
-	switch c.service {
-	case "Users":
-		usersClient = getUserServiceClient(conn)
-		respJson, err := callUserMethod(usersClient, c)
+	if len(mode) == 0 {
+		err = validateGrpcConfig(c)
 		if err != nil {
 			return err
 		}
-		fmt.Fprint(w, string(respJson))
-	case "Repo":
-		repoClient := getRepoServiceClient(conn)
-		respJson, err := callRepoMethod(repoClient, c)
+	}
+	err = validateTLSOptions(c.tls)
+	if err != nil {
+		return err
+	}
+
+	benchSet := set
+	if len(c.requestFile) != 0 && !c.bench.enabled {
+		// -concurrency also applies to a batch of unary calls.
+		benchSet = maps.Clone(set)
+		delete(benchSet, "concurrency")
+		if c.bench.load.concurrency < 1 {
+			return ErrInvalidBenchConcurrency
+		}
+	}
+	err = validateBenchOptions(c.bench, benchSet)
+	if err != nil {
+		return err
+	}
+	if c.bench.enabled {
+		if len(mode) != 0 {
+			return ErrInvalidBenchMode
+		}
+		if c.connections < 1 || c.numCalls < 1 {
+			return ErrInvalidBenchConnections
+		}
+		c.bench.load.total = c.numCalls
+		if c.bench.load.duration > 0 && !set["num-calls"] {
+			c.bench.load.total = 0
+		}
+		return statusError(tlsError(runGrpcBench(ctx, c, w)), errorFormat)
+	}
+
+	conn, err := setupGrpcConn(c)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch mode {
+	case "list", "describe":
+		ctx, cancel := callContext(ctx, c)
+		defer cancel()
+		if mode == "list" {
+			err = listServices(ctx, newDescriptorSource(conn), c, w)
+		} else {
+			err = describe(ctx, newDescriptorSource(conn), c, symbol, w)
+		}
+	default:
+		err = callMethod(ctx, conn, c, w)
+	}
+	return statusError(tlsError(err), errorFormat)
+}
+
+// runGrpcBench opens c.connections connections to the server and load
+// tests c.method over them.
+func runGrpcBench(ctx context.Context, c grpcConfig, w io.Writer) error {
+	conns := make([]grpc.ClientConnInterface, c.connections)
+	for i := range conns {
+		conn, err := setupGrpcConn(c)
 		if err != nil {
 			return err
 		}
-		fmt.Fprintln(w, string(respJson))
-	default:
-		return errors.New("unrecognized service")
+		defer conn.Close()
+		conn.Connect()
+		conns[i] = conn
 	}
-	return nil
+	return benchGrpc(ctx, conns, c, w)
 }
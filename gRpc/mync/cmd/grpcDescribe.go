@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func methodShape(md protoreflect.MethodDescriptor) string {
+	switch {
+	case md.IsStreamingClient() && md.IsStreamingServer():
+		return "bidi-streaming"
+	case md.IsStreamingClient():
+		return "client-streaming"
+	case md.IsStreamingServer():
+		return "server-streaming"
+	default:
+		return "unary"
+	}
+}
+
+// listServices prints the services on the server or, if a service is
+// given, its methods along with their streaming shape.
+func listServices(ctx context.Context, src *descriptorSource, c grpcConfig, w io.Writer) error {
+	if len(c.service) == 0 {
+		services, err := src.listServices(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range services {
+			fmt.Fprintln(w, s)
+		}
+		return nil
+	}
+
+	sd, err := src.findService(ctx, c.service)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < sd.Methods().Len(); i++ {
+		md := sd.Methods().Get(i)
+		fmt.Fprintf(w, "%s %s\n", md.FullName(), methodShape(md))
+	}
+	return nil
+}
+
+// describeSymbol prints a service, method or message in proto syntax
+// followed by every message and enum it refers to.
+func describeSymbol(ctx context.Context, src *descriptorSource, symbol string, w io.Writer) error {
+	desc, err := src.findSymbol(ctx, symbol)
+	if err == protoregistry.NotFound {
+		// Let a service be named without its package.
+		desc, err = src.findService(ctx, symbol)
+		if err == ErrUnrecognizedService {
+			return fmt.Errorf("symbol %s not found", symbol)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	p := protoPrinter{w: w, seen: map[protoreflect.FullName]bool{}}
+	separate := true
+	switch d := desc.(type) {
+	case protoreflect.ServiceDescriptor:
+		p.header(d)
+		p.service(d, "")
+		for i := 0; i < d.Methods().Len(); i++ {
+			p.collectMethod(d.Methods().Get(i))
+		}
+	case protoreflect.MethodDescriptor:
+		p.header(d)
+		p.method(d, "")
+		p.collectMethod(d)
+	case protoreflect.MessageDescriptor:
+		p.collectMessage(d)
+		separate = false
+	case protoreflect.EnumDescriptor:
+		p.collectEnum(d)
+		separate = false
+	default:
+		return fmt.Errorf("cannot describe %s", symbol)
+	}
+
+	for _, d := range p.types {
+		if separate {
+			fmt.Fprintln(w)
+		}
+		separate = true
+		p.header(d)
+		switch d := d.(type) {
+		case protoreflect.MessageDescriptor:
+			p.message(d, "")
+		case protoreflect.EnumDescriptor:
+			p.enum(d, "")
+		}
+	}
+	return nil
+}
+
+// protoPrinter writes descriptors in proto syntax. types collects the top
+// level messages and enums referenced by what was printed so far.
+type protoPrinter struct {
+	w     io.Writer
+	seen  map[protoreflect.FullName]bool
+	types []protoreflect.Descriptor
+}
+
+func (p *protoPrinter) header(d protoreflect.Descriptor) {
+	fmt.Fprintf(p.w, "// %s (%s)\n", d.FullName(), d.ParentFile().Path())
+}
+
+// topLevel returns the outermost message containing d, or d itself.
+func topLevel(d protoreflect.Descriptor) protoreflect.Descriptor {
+	for {
+		parent, ok := d.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			return d
+		}
+		d = parent
+	}
+}
+
+func (p *protoPrinter) collectMethod(md protoreflect.MethodDescriptor) {
+	p.collectMessage(md.Input())
+	p.collectMessage(md.Output())
+}
+
+func (p *protoPrinter) collectMessage(md protoreflect.MessageDescriptor) {
+	if p.seen[md.FullName()] {
+		return
+	}
+	p.seen[md.FullName()] = true
+	if top := topLevel(md); !md.IsMapEntry() && top == md {
+		p.types = append(p.types, md)
+	} else if !md.IsMapEntry() {
+		p.collectMessage(top.(protoreflect.MessageDescriptor))
+	}
+
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		switch {
+		case fd.Message() != nil:
+			p.collectMessage(fd.Message())
+		case fd.Enum() != nil:
+			p.collectEnum(fd.Enum())
+		}
+	}
+	for i := 0; i < md.Messages().Len(); i++ {
+		p.collectMessage(md.Messages().Get(i))
+	}
+}
+
+func (p *protoPrinter) collectEnum(ed protoreflect.EnumDescriptor) {
+	top := topLevel(ed)
+	if md, ok := top.(protoreflect.MessageDescriptor); ok {
+		p.collectMessage(md)
+		return
+	}
+	if p.seen[ed.FullName()] {
+		return
+	}
+	p.seen[ed.FullName()] = true
+	p.types = append(p.types, ed)
+}
+
+func (p *protoPrinter) service(sd protoreflect.ServiceDescriptor, indent string) {
+	fmt.Fprintf(p.w, "%sservice %s {\n", indent, sd.Name())
+	for i := 0; i < sd.Methods().Len(); i++ {
+		p.method(sd.Methods().Get(i), indent+"  ")
+	}
+	fmt.Fprintf(p.w, "%s}\n", indent)
+}
+
+func (p *protoPrinter) method(md protoreflect.MethodDescriptor, indent string) {
+	var in, out string
+	if md.IsStreamingClient() {
+		in = "stream "
+	}
+	if md.IsStreamingServer() {
+		out = "stream "
+	}
+	fmt.Fprintf(p.w, "%srpc %s(%s%s) returns (%s%s);\n",
+		indent, md.Name(), in, md.Input().FullName(), out, md.Output().FullName())
+}
+
+func fieldType(fd protoreflect.FieldDescriptor) string {
+	switch {
+	case fd.IsMap():
+		return fmt.Sprintf("map<%s, %s>", fieldType(fd.MapKey()), fieldType(fd.MapValue()))
+	case fd.Message() != nil:
+		return string(fd.Message().FullName())
+	case fd.Enum() != nil:
+		return string(fd.Enum().FullName())
+	default:
+		return fd.Kind().String()
+	}
+}
+
+func (p *protoPrinter) field(fd protoreflect.FieldDescriptor, indent string) {
+	var label string
+	switch {
+	case fd.IsMap():
+	case fd.Cardinality() == protoreflect.Repeated:
+		label = "repeated "
+	case fd.Cardinality() == protoreflect.Required:
+		label = "required "
+	case fd.HasOptionalKeyword():
+		label = "optional "
+	}
+	fmt.Fprintf(p.w, "%s%s%s %s = %d;\n", indent, label, fieldType(fd), fd.Name(), fd.Number())
+}
+
+func (p *protoPrinter) message(md protoreflect.MessageDescriptor, indent string) {
+	fmt.Fprintf(p.w, "%smessage %s {\n", indent, md.Name())
+	inner := indent + "  "
+
+	printed := map[protoreflect.FullName]bool{}
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		od := fd.ContainingOneof()
+		if od == nil || od.IsSynthetic() {
+			p.field(fd, inner)
+			continue
+		}
+		if printed[od.FullName()] {
+			continue
+		}
+		printed[od.FullName()] = true
+		fmt.Fprintf(p.w, "%soneof %s {\n", inner, od.Name())
+		for j := 0; j < od.Fields().Len(); j++ {
+			p.field(od.Fields().Get(j), inner+"  ")
+		}
+		fmt.Fprintf(p.w, "%s}\n", inner)
+	}
+
+	for i := 0; i < md.Messages().Len(); i++ {
+		nested := md.Messages().Get(i)
+		if !nested.IsMapEntry() {
+			p.message(nested, inner)
+		}
+	}
+	for i := 0; i < md.Enums().Len(); i++ {
+		p.enum(md.Enums().Get(i), inner)
+	}
+	fmt.Fprintf(p.w, "%s}\n", indent)
+}
+
+func (p *protoPrinter) enum(ed protoreflect.EnumDescriptor, indent string) {
+	fmt.Fprintf(p.w, "%senum %s {\n", indent, ed.Name())
+	for i := 0; i < ed.Values().Len(); i++ {
+		v := ed.Values().Get(i)
+		fmt.Fprintf(p.w, "%s  %s = %d;\n", indent, v.Name(), v.Number())
+	}
+	fmt.Fprintf(p.w, "%s}\n", indent)
+}
+
+// describe prints the symbol given on the command line, the service and
+// method selected by the flags, or every service on the server.
+func describe(ctx context.Context, src *descriptorSource, c grpcConfig, symbol string, w io.Writer) error {
+	if len(symbol) != 0 {
+		return describeSymbol(ctx, src, symbol, w)
+	}
+	if len(c.service) != 0 {
+		sd, err := src.findService(ctx, c.service)
+		if err != nil {
+			return err
+		}
+		symbol = string(sd.FullName())
+		if len(c.method) != 0 {
+			if sd.Methods().ByName(protoreflect.Name(c.method)) == nil {
+				return ErrInvalidGrpcMethod
+			}
+			symbol += "." + c.method
+		}
+		return describeSymbol(ctx, src, symbol, w)
+	}
+
+	services, err := src.listServices(ctx)
+	if err != nil {
+		return err
+	}
+	for i, s := range services {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		err := describeSymbol(ctx, src, s, w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxRequestLineSize bounds a single NDJSON request message.
+const maxRequestLineSize = 16 * 1024 * 1024
+
+func methodPath(md protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+}
+
+func createRequest(src *descriptorSource, md protoreflect.MethodDescriptor, jsonQuery string) (*dynamicpb.Message, error) {
+	req := dynamicpb.NewMessage(md.Input())
+	if len(jsonQuery) == 0 {
+		return req, nil
+	}
+	opts := protojson.UnmarshalOptions{Resolver: src.types()}
+	return req, opts.Unmarshal([]byte(jsonQuery), req)
+}
+
+func getResponseJson(c grpcConfig, src *descriptorSource, resp proto.Message) ([]byte, error) {
+	// Queries can select fields with default values, which are left out
+	// otherwise.
+	opts := protojson.MarshalOptions{
+		Resolver:        src.types(),
+		EmitUnpopulated: c.emitUnpopulated || c.query != nil,
+	}
+	if c.prettyPrint {
+		opts.Multiline = true
+	}
+	return opts.Marshal(resp)
+}
+
+// writeGrpcResponse prints a response, or the values selected from it with
+// -query, as JSON.
+func writeGrpcResponse(w io.Writer, c grpcConfig, respJson []byte) error {
+	if c.query != nil {
+		return writeQuery(w, c.query, respJson)
+	}
+	fmt.Fprintln(w, string(respJson))
+	return nil
+}
+
+func invokeUnary(ctx context.Context, conn grpc.ClientConnInterface, src *descriptorSource, md protoreflect.MethodDescriptor, c grpcConfig) ([]byte, error) {
+	req, err := createRequest(src, md, c.request)
+	if err != nil {
+		return nil, InvalidInputError{Err: err}
+	}
+	resp := dynamicpb.NewMessage(md.Output())
+	var header, trailer metadata.MD
+	err = conn.Invoke(ctx, methodPath(md), req, resp, grpc.Header(&header), grpc.Trailer(&trailer))
+	if c.verbose {
+		writeCallInfo(stderr, src, header, trailer, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return getResponseJson(c, src, resp)
+}
+
+func openRequestFile(path string) (io.ReadCloser, error) {
+	if len(path) == 0 || path == "-" {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(path)
+}
+
+// sendRequests sends the request messages of a streaming call. Client and
+// bidi streaming methods read one JSON message per line from -request-file
+// or stdin unless a single -request is given.
+func sendRequests(stream grpc.ClientStream, src *descriptorSource, md protoreflect.MethodDescriptor, c grpcConfig, sent *atomic.Int64) error {
+	if !md.IsStreamingClient() || len(c.request) != 0 {
+		req, err := createRequest(src, md, c.request)
+		if err != nil {
+			return InvalidInputError{Err: err}
+		}
+		err = stream.SendMsg(req)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		sent.Add(1)
+		return stream.CloseSend()
+	}
+
+	r, err := openRequestFile(c.requestFile)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRequestLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		data := strings.TrimSpace(scanner.Text())
+		if len(data) == 0 {
+			continue
+		}
+		req, err := createRequest(src, md, data)
+		if err != nil {
+			return InvalidInputError{Err: fmt.Errorf("request on line %d: %w", line, err)}
+		}
+		err = stream.SendMsg(req)
+		if err == io.EOF {
+			// The server ended the call, RecvMsg reports why.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		sent.Add(1)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+	return stream.CloseSend()
+}
+
+// invokeStream drives server, client and bidi streaming calls, printing
+// each response as a line of JSON as it arrives and a summary on stderr.
+func invokeStream(ctx context.Context, conn grpc.ClientConnInterface, src *descriptorSource, md protoreflect.MethodDescriptor, c grpcConfig, w io.Writer) error {
+	desc := &grpc.StreamDesc{
+		StreamName:    string(md.Name()),
+		ServerStreams: md.IsStreamingServer(),
+		ClientStreams: md.IsStreamingClient(),
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := conn.NewStream(ctx, desc, methodPath(md))
+	if err != nil {
+		return err
+	}
+
+	var sent atomic.Int64
+	sendErr := make(chan error, 1)
+	go func() {
+		err := sendRequests(stream, src, md, c, &sent)
+		sendErr <- err
+		if err != nil {
+			cancel()
+		}
+	}()
+
+	var received int
+	for {
+		resp := dynamicpb.NewMessage(md.Output())
+		err = stream.RecvMsg(resp)
+		if err != nil {
+			break
+		}
+		received++
+		respJson, err := getResponseJson(c, src, resp)
+		if err != nil {
+			return err
+		}
+		err = writeGrpcResponse(w, c, respJson)
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case serr := <-sendErr:
+		if serr != nil {
+			return serr
+		}
+	default:
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if c.verbose {
+		// Header returns the headers received so far if the call failed.
+		header, _ := stream.Header()
+		writeCallInfo(stderr, src, header, stream.Trailer(), err)
+	}
+	fmt.Fprintf(stderr, "status=%s sent=%d received=%d\n", status.Code(err), sent.Load(), received)
+	return err
+}
+
+// callMethod resolves c.service and c.method on the server, invokes the
+// method and writes the JSON responses to w. A unary method is called once
+// for every request in -request-file, see invokeBatch. Each call, as well as
+// the lookup of the method, is bounded by -deadline.
+func callMethod(ctx context.Context, conn grpc.ClientConnInterface, c grpcConfig, w io.Writer) error {
+	if len(c.method) == 0 {
+		return ErrInvalidGrpcMethod
+	}
+	src := newDescriptorSource(conn)
+	lookupCtx, cancel := callContext(ctx, c)
+	md, err := src.findMethod(lookupCtx, c.service, c.method)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if c.verbose {
+		sent, _ := metadata.FromOutgoingContext(ctx)
+		writeMetadata(stderr, "Request metadata", sent)
+	}
+	streaming := md.IsStreamingClient() || md.IsStreamingServer()
+	if streaming && c.bench.load.concurrency > 1 {
+		return ErrInvalidBatchConcurrency
+	}
+	if !streaming && len(c.requestFile) != 0 {
+		if c.query != nil {
+			return ErrInvalidBatchQuery
+		}
+		return invokeBatch(ctx, conn, src, md, c, w)
+	}
+
+	ctx, cancel = callContext(ctx, c)
+	defer cancel()
+	if streaming {
+		return invokeStream(ctx, conn, src, md, c, w)
+	}
+	respJson, err := invokeUnary(ctx, conn, src, md, c)
+	if err != nil {
+		return err
+	}
+	return writeGrpcResponse(w, c, respJson)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	// Registers the google.rpc error detail messages, such as ErrorInfo and
+	// BadRequest, so that -verbose can print them.
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// parseMetadata parses -metadata key=value pairs. Values of binary keys,
+// those ending in -bin, are base64 encoded on the command line.
+func parseMetadata(pairs []string) (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, p := range pairs {
+		key, value, ok := strings.Cut(p, "=")
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMetadata, p)
+		}
+		key = strings.ToLower(key)
+		if strings.HasSuffix(key, "-bin") {
+			data, err := decodeBase64(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q is not base64", ErrInvalidMetadata, p)
+			}
+			value = string(data)
+		}
+		md.Append(key, value)
+	}
+	return md, nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		data, err := enc.DecodeString(s)
+		if err == nil {
+			return data, nil
+		}
+	}
+	return nil, base64.CorruptInputError(0)
+}
+
+// statusDetailsKey is the trailer carrying the google.rpc.Status of a call,
+// which writeCallInfo prints decoded.
+const statusDetailsKey = "grpc-status-details-bin"
+
+func writeMetadata(w io.Writer, title string, md metadata.MD) {
+	fmt.Fprintf(w, "%s:\n", title)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == statusDetailsKey {
+			continue
+		}
+		for _, v := range md[k] {
+			if strings.HasSuffix(k, "-bin") {
+				v = base64.StdEncoding.EncodeToString([]byte(v))
+			}
+			fmt.Fprintf(w, "  %s: %s\n", k, v)
+		}
+	}
+}
+
+// writeCallInfo prints what -verbose shows about a finished call: the
+// response headers and trailers and the status with its details.
+func writeCallInfo(w io.Writer, src *descriptorSource, header, trailer metadata.MD, err error) {
+	writeMetadata(w, "Response headers", header)
+	writeMetadata(w, "Response trailers", trailer)
+
+	st := status.Convert(err)
+	fmt.Fprintf(w, "Status: %s (%d)\n", st.Code(), st.Code())
+	if len(st.Message()) != 0 {
+		fmt.Fprintf(w, "Message: %s\n", st.Message())
+	}
+	details := st.Proto().GetDetails()
+	if len(details) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Details:")
+	for _, d := range details {
+		fmt.Fprintf(w, "  %s\n", formatDetail(src, d))
+	}
+}
+
+// formatDetail prints a status detail as JSON if its type is a google.rpc
+// error detail or known to the server, and its raw bytes otherwise.
+func formatDetail(src *descriptorSource, detail *anypb.Any) string {
+	data, err := protojson.Marshal(detail)
+	if err != nil && src != nil {
+		data, err = protojson.MarshalOptions{Resolver: src.types()}.Marshal(detail)
+	}
+	if err != nil {
+		return fmt.Sprintf("%s: %s", detail.GetTypeUrl(), base64.StdEncoding.EncodeToString(detail.GetValue()))
+	}
+	return string(data)
+}
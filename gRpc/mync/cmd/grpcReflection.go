@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	svc "service"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// localFiles are the protos compiled into mync. They are used when the
+// server does not register the reflection service.
+var localFiles = []protoreflect.FileDescriptor{
+	svc.File_users_proto,
+	svc.File_repositories_proto,
+}
+
+// descriptorSource resolves services and messages through the server
+// reflection API, falling back to localFiles if reflection is unavailable.
+type descriptorSource struct {
+	client rpb.ServerReflectionClient
+	files  *protoregistry.Files
+	local  bool
+}
+
+func newDescriptorSource(conn grpc.ClientConnInterface) *descriptorSource {
+	return &descriptorSource{
+		client: rpb.NewServerReflectionClient(conn),
+		files:  new(protoregistry.Files),
+	}
+}
+
+func (d *descriptorSource) useLocalFiles() error {
+	d.local = true
+	d.files = new(protoregistry.Files)
+	for _, fd := range localFiles {
+		err := d.files.RegisterFile(fd)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *descriptorSource) types() *dynamicpb.Types {
+	return dynamicpb.NewTypes(d.files)
+}
+
+func (d *descriptorSource) send(ctx context.Context, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := d.client.ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = stream.Send(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, status.Error(codes.Code(e.ErrorCode), e.ErrorMessage)
+	}
+	return resp, nil
+}
+
+// reflect sends req, switching to localFiles and returning a nil response
+// if the server doesn't implement the reflection service.
+func (d *descriptorSource) reflect(ctx context.Context, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if d.local {
+		return nil, nil
+	}
+	resp, err := d.send(ctx, req)
+	if status.Code(err) == codes.Unimplemented {
+		return nil, d.useLocalFiles()
+	}
+	return resp, err
+}
+
+func (d *descriptorSource) listServices(ctx context.Context) ([]string, error) {
+	resp, err := d.reflect(ctx, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var services []string
+	if d.local {
+		d.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			for i := 0; i < fd.Services().Len(); i++ {
+				services = append(services, string(fd.Services().Get(i).FullName()))
+			}
+			return true
+		})
+	} else {
+		for _, s := range resp.GetListServicesResponse().GetService() {
+			services = append(services, s.Name)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// findSymbol loads the file defining the fully qualified name from the
+// server, along with its dependencies.
+func (d *descriptorSource) findSymbol(ctx context.Context, name string) (protoreflect.Descriptor, error) {
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err == nil || d.local {
+		return desc, err
+	}
+
+	resp, err := d.reflect(ctx, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: name},
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, protoregistry.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp != nil {
+		err = d.addFiles(ctx, resp.GetFileDescriptorResponse().GetFileDescriptorProto())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d.files.FindDescriptorByName(protoreflect.FullName(name))
+}
+
+func (d *descriptorSource) addFiles(ctx context.Context, raw [][]byte) error {
+	pending := map[string]*descriptorpb.FileDescriptorProto{}
+	for _, b := range raw {
+		fdp := &descriptorpb.FileDescriptorProto{}
+		err := proto.Unmarshal(b, fdp)
+		if err != nil {
+			return err
+		}
+		pending[fdp.GetName()] = fdp
+	}
+	for name := range pending {
+		err := d.registerFile(ctx, name, pending)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *descriptorSource) registerFile(ctx context.Context, name string, pending map[string]*descriptorpb.FileDescriptorProto) error {
+	if _, err := d.files.FindFileByPath(name); err == nil {
+		return nil
+	}
+
+	fdp, ok := pending[name]
+	if !ok {
+		resp, err := d.send(ctx, &rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		})
+		if err != nil {
+			// Well-known types such as google/protobuf/timestamp.proto
+			// are linked into mync already.
+			if fd, gerr := protoregistry.GlobalFiles.FindFileByPath(name); gerr == nil {
+				return d.files.RegisterFile(fd)
+			}
+			return fmt.Errorf("resolving %s: %w", name, err)
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			f := &descriptorpb.FileDescriptorProto{}
+			err := proto.Unmarshal(b, f)
+			if err != nil {
+				return err
+			}
+			pending[f.GetName()] = f
+		}
+		fdp, ok = pending[name]
+		if !ok {
+			return fmt.Errorf("server did not return file %s", name)
+		}
+	}
+
+	for _, dep := range fdp.GetDependency() {
+		err := d.registerFile(ctx, dep, pending)
+		if err != nil {
+			return err
+		}
+	}
+	fd, err := protodesc.NewFile(fdp, d.files)
+	if err != nil {
+		return err
+	}
+	return d.files.RegisterFile(fd)
+}
+
+// findService resolves a fully qualified service name. A name without a
+// package, such as Health, matches a listed service if it is unambiguous.
+func (d *descriptorSource) findService(ctx context.Context, name string) (protoreflect.ServiceDescriptor, error) {
+	desc, err := d.findSymbol(ctx, name)
+	if err != nil && err != protoregistry.NotFound {
+		return nil, err
+	}
+	if sd, ok := desc.(protoreflect.ServiceDescriptor); ok {
+		return sd, nil
+	}
+
+	services, err := d.listServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, s := range services {
+		if s == name || strings.HasSuffix(s, "."+name) {
+			matches = append(matches, s)
+		}
+	}
+	if len(matches) != 1 {
+		return nil, ErrUnrecognizedService
+	}
+	desc, err = d.findSymbol(ctx, matches[0])
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, ErrUnrecognizedService
+	}
+	return sd, nil
+}
+
+func (d *descriptorSource) findMethod(ctx context.Context, service, method string) (protoreflect.MethodDescriptor, error) {
+	sd, err := d.findService(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, ErrInvalidGrpcMethod
+	}
+	return md, nil
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Exit codes of mync. A gRPC call that fails with a status exits with
+// ExitGrpcStatus plus the status code, so scripts can tell client errors
+// such as InvalidArgument (13) and NotFound (15) from outages such as
+// DeadlineExceeded (14) and Unavailable (24).
+const (
+	ExitOK         = 0
+	ExitError      = 1
+	ExitGrpcStatus = 10
+)
+
+const exitCodesUsage = `
+Exit codes:
+  0     The call succeeded
+  1     Invalid usage or an error without a gRPC status
+  10+N  The call failed with gRPC status code N:
+        11 Canceled, 12 Unknown, 13 InvalidArgument, 14 DeadlineExceeded,
+        15 NotFound, 16 AlreadyExists, 17 PermissionDenied,
+        18 ResourceExhausted, 19 FailedPrecondition, 20 Aborted,
+        21 OutOfRange, 22 Unimplemented, 23 Internal, 24 Unavailable,
+        25 DataLoss, 26 Unauthenticated
+`
+
+// GrpcStatusError is returned by HandleGrpc when a call fails with a gRPC
+// status. It prints as the underlying error or, with -error-format json, as
+// a JSON object with the code, message and details of the status.
+type GrpcStatusError struct {
+	Err    error
+	Status *status.Status
+	JSON   bool
+}
+
+type grpcErrorJson struct {
+	Code       string            `json:"code"`
+	CodeNumber codes.Code        `json:"codeNumber"`
+	Message    string            `json:"message"`
+	Details    []json.RawMessage `json:"details,omitempty"`
+	ExitCode   int               `json:"exitCode"`
+}
+
+func (e GrpcStatusError) Error() string {
+	if !e.JSON {
+		return e.Err.Error()
+	}
+	out := grpcErrorJson{
+		Code:       e.Status.Code().String(),
+		CodeNumber: e.Status.Code(),
+		Message:    e.Status.Message(),
+		ExitCode:   e.ExitCode(),
+	}
+	for _, d := range e.Status.Proto().GetDetails() {
+		detail := formatDetail(nil, d)
+		if !json.Valid([]byte(detail)) {
+			quoted, _ := json.Marshal(detail)
+			detail = string(quoted)
+		}
+		out.Details = append(out.Details, json.RawMessage(detail))
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return e.Err.Error()
+	}
+	return string(data)
+}
+
+func (e GrpcStatusError) Unwrap() error {
+	return e.Err
+}
+
+func (e GrpcStatusError) GRPCStatus() *status.Status {
+	return e.Status
+}
+
+// ExitCode returns the process exit code for the status.
+func (e GrpcStatusError) ExitCode() int {
+	return ExitGrpcStatus + int(e.Status.Code())
+}
+
+// statusError wraps errors carrying a gRPC status in a GrpcStatusError.
+func statusError(err error, errorFormat string) error {
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) || se.GRPCStatus().Code() == codes.OK {
+		return err
+	}
+	return GrpcStatusError{Err: err, Status: se.GRPCStatus(), JSON: errorFormat == "json"}
+}
+
+// callContext bounds a call by -deadline, if set.
+func callContext(ctx context.Context, c grpcConfig) (context.Context, context.CancelFunc) {
+	if c.deadline > 0 {
+		return context.WithTimeout(ctx, c.deadline)
+	}
+	return context.WithCancel(ctx)
+}
+
+// ExitCode returns the process exit code for an error returned by a mync
+// command.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var se GrpcStatusError
+	if errors.As(err, &se) {
+		return se.ExitCode()
+	}
+	return ExitError
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCallBatch(t *testing.T) {
+	s, l := startTestStreamServer()
+	defer s.GracefulStop()
+	conn := dialTestServer(t, l)
+	defer conn.Close()
+
+	origStdin := stdin
+	defer func() { stdin = origStdin }()
+
+	var input strings.Builder
+	var expected []string
+	for i := 0; i < 20; i++ {
+		switch i {
+		case 7:
+			input.WriteString("{\"email\":\"nobody\",\"id\":\"user-7\"}\n\n")
+			expected = append(expected, `{"index":7,"error":{"code":"InvalidArgument","message":"invalid email address"}}`)
+		case 12:
+			input.WriteString("not json\n")
+			expected = append(expected, `{"index":12,"error":{"message":"request on line 14: `)
+		default:
+			fmt.Fprintf(&input, "{\"email\":\"user%d@example.com\",\"id\":\"user-%d\"}\n", i, i)
+			expected = append(expected, fmt.Sprintf(`{"index":%d,"response":{"user":{"id":"user-%d","firstName":"user%d","lastName":"example.com","age":36}}}`, i, i, i))
+		}
+	}
+
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("concurrency%d", concurrency), func(t *testing.T) {
+			stdin = strings.NewReader(input.String())
+			c := grpcConfig{service: "Users", method: "GetUser", requestFile: "-"}
+			c.bench.load.concurrency = concurrency
+			w := new(bytes.Buffer)
+
+			err := callMethod(context.Background(), conn, c, w)
+			if !errors.Is(err, ErrBatchFailed) || !strings.Contains(err.Error(), "2 of 20 calls failed") {
+				t.Errorf("Expected 2 of 20 calls to fail, got: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(w.String()), "\n")
+			if len(lines) != len(expected) {
+				t.Fatalf("Expected %d lines, got:\n%s", len(expected), w.String())
+			}
+			for i := range lines {
+				got := lines[i]
+				if strings.HasPrefix(expected[i], `{"index":12`) {
+					got = got[:len(expected[i])]
+				}
+				if diff := cmp.Diff(expected[i], got); diff != "" {
+					t.Errorf("Unexpected result %d (-want +got):\n%s", i, diff)
+				}
+			}
+		})
+	}
+
+	stdin = strings.NewReader("{\"email\":\"john@doe.com\"}\n")
+	c := grpcConfig{service: "Users", method: "GetUser", requestFile: "-", query: &jsonQuery{expr: "."}}
+	err := callMethod(context.Background(), conn, c, new(bytes.Buffer))
+	if !errors.Is(err, ErrInvalidBatchQuery) {
+		t.Errorf("Expected ErrInvalidBatchQuery, got: %v", err)
+	}
+
+	c = grpcConfig{service: "Users", method: "GetHelp", requestFile: "-"}
+	c.bench.load.concurrency = 2
+	err = callMethod(context.Background(), conn, c, new(bytes.Buffer))
+	if !errors.Is(err, ErrInvalidBatchConcurrency) {
+		t.Errorf("Expected ErrInvalidBatchConcurrency, got: %v", err)
+	}
+}
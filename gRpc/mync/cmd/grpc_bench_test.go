@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+)
+
+func TestBenchGrpc(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         grpcConfig
+		input     string
+		calls     int
+		codes     map[string]int
+		messages  int
+		streaming bool
+		errMsg    string
+	}{
+		{
+			name:  "test1",
+			c:     grpcConfig{service: "Users", method: "GetUser", request: `{"email":"jane@doe.com","id":"1"}`},
+			calls: 20,
+			codes: map[string]int{"OK": 20},
+		},
+		{
+			name:  "test2",
+			c:     grpcConfig{service: "Users", method: "GetUser", request: `{"email":"jane","id":"1"}`},
+			calls: 10,
+			codes: map[string]int{"InvalidArgument": 10},
+		},
+		{
+			name:      "test3",
+			c:         grpcConfig{service: "Repo", method: "GetRepos", request: `{"id":"1","creatorId":"user-1"}`},
+			calls:     8,
+			codes:     map[string]int{"OK": 8},
+			messages:  40,
+			streaming: true,
+		},
+		{
+			name:      "test4",
+			c:         grpcConfig{service: "Users", method: "GetHelp"},
+			input:     "{\"request\":\"help\"}\n{\"request\":\"more help\"}\n",
+			calls:     5,
+			codes:     map[string]int{"OK": 5},
+			messages:  10,
+			streaming: true,
+		},
+		{
+			name:   "test5",
+			c:      grpcConfig{service: "Users", method: "GetUser", requestFile: "-"},
+			errMsg: ErrInvalidGrpcRequestFile.Error(),
+		},
+	}
+
+	s, l := startTestStreamServer()
+	defer s.GracefulStop()
+	conns := []grpc.ClientConnInterface{dialTestServer(t, l), dialTestServer(t, l)}
+	for _, conn := range conns {
+		defer conn.(*grpc.ClientConn).Close()
+	}
+
+	origStdin := stdin
+	defer func() { stdin = origStdin }()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdin = strings.NewReader(tc.input)
+			tc.c.bench = benchOptions{enabled: true, format: "json", load: loadOptions{concurrency: 3, total: tc.calls}}
+
+			w := new(bytes.Buffer)
+			err := benchGrpc(context.Background(), conns, tc.c, w)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if err != nil {
+				return
+			}
+
+			var sum loadSummary
+			err = json.Unmarshal(w.Bytes(), &sum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sum.Calls != tc.calls {
+				t.Errorf("Expected %d calls, got: %d", tc.calls, sum.Calls)
+			}
+			if diff := cmp.Diff(tc.codes, sum.StatusCodes); diff != "" {
+				t.Errorf("Status codes mismatch (-want +got):\n%s", diff)
+			}
+			if (sum.Streaming != nil) != tc.streaming {
+				t.Fatalf("Expected streaming summary: %v, got: %+v", tc.streaming, sum.Streaming)
+			}
+			if tc.streaming && sum.Streaming.Messages != tc.messages {
+				t.Errorf("Expected %d messages, got: %d", tc.messages, sum.Streaming.Messages)
+			}
+		})
+	}
+}
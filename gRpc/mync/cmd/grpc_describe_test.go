@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestListServices(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      grpcConfig
+		output string
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			c:      grpcConfig{},
+			output: "Repo\nUsers\ngrpc.reflection.v1.ServerReflection\n",
+		},
+		{
+			name:   "test2",
+			c:      grpcConfig{service: "Repo"},
+			output: "Repo.GetRepos server-streaming\nRepo.CreateBuild server-streaming\nRepo.CreateRepo client-streaming\n",
+		},
+		{
+			name:   "test3",
+			c:      grpcConfig{service: "Users"},
+			output: "Users.GetUser unary\nUsers.GetHelp bidi-streaming\n",
+		},
+		{
+			name:   "test4",
+			c:      grpcConfig{service: "Gopher"},
+			errMsg: ErrUnrecognizedService.Error(),
+		},
+	}
+
+	s, l := startTestStreamServer()
+	defer s.GracefulStop()
+	conn := dialTestServer(t, l)
+	defer conn.Close()
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := listServices(context.Background(), newDescriptorSource(conn), tc.c, w)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if diff := cmp.Diff(w.String(), tc.output); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      grpcConfig
+		symbol string
+		output string
+		errMsg string
+	}{
+		{
+			name: "test1",
+			c:    grpcConfig{service: "Repo", method: "CreateBuild"},
+			output: `// Repo.CreateBuild (repositories.proto)
+rpc CreateBuild(Repository) returns (stream RepoBuildLog);
+
+// Repository (repositories.proto)
+message Repository {
+  string id = 1;
+  string name = 2;
+  string url = 3;
+  User owner = 4;
+}
+
+// User (users.proto)
+message User {
+  string id = 1;
+  string first_name = 2;
+  string last_name = 3;
+  int32 age = 4;
+}
+
+// RepoBuildLog (repositories.proto)
+message RepoBuildLog {
+  string log_line = 1;
+  google.protobuf.Timestamp timestamp = 2;
+}
+
+// google.protobuf.Timestamp (google/protobuf/timestamp.proto)
+message Timestamp {
+  int64 seconds = 1;
+  int32 nanos = 2;
+}
+`,
+		},
+		{
+			name:   "test2",
+			symbol: "RepoCreateRequest",
+			output: `// RepoCreateRequest (repositories.proto)
+message RepoCreateRequest {
+  oneof body {
+    RepoContext context = 1;
+    bytes data = 2;
+  }
+}
+
+// RepoContext (repositories.proto)
+message RepoContext {
+  string creator_id = 1;
+  string name = 2;
+}
+`,
+		},
+		{
+			name:   "test3",
+			symbol: "Users",
+			output: `// Users (users.proto)
+service Users {
+  rpc GetUser(UserGetRequest) returns (UserGetReply);
+  rpc GetHelp(stream UserHelpRequest) returns (stream UserHelpReply);
+}
+`,
+		},
+		{
+			name:   "test4",
+			c:      grpcConfig{service: "Repo", method: "DeleteRepo"},
+			errMsg: ErrInvalidGrpcMethod.Error(),
+		},
+		{
+			name:   "test5",
+			symbol: "RepoDeleteRequest",
+			errMsg: "symbol RepoDeleteRequest not found",
+		},
+	}
+
+	s, l := startTestStreamServer()
+	defer s.GracefulStop()
+	conn := dialTestServer(t, l)
+	defer conn.Close()
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := describe(context.Background(), newDescriptorSource(conn), tc.c, tc.symbol, w)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			// Only the head of a service description is checked.
+			output := w.String()
+			if tc.symbol == "Users" {
+				output = output[:strings.Index(output, "\n\n")+1]
+			}
+			if diff := cmp.Diff(output, tc.output); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestDescribeNestedTypes(t *testing.T) {
+	s, l := startTestReflectionServer()
+	defer s.GracefulStop()
+	conn := dialTestServer(t, l)
+	defer conn.Close()
+
+	expected := `// grpc.health.v1.HealthCheckResponse (grpc/health/v1/health.proto)
+message HealthCheckResponse {
+  grpc.health.v1.HealthCheckResponse.ServingStatus status = 1;
+  enum ServingStatus {
+    UNKNOWN = 0;
+    SERVING = 1;
+    NOT_SERVING = 2;
+    SERVICE_UNKNOWN = 3;
+  }
+}
+`
+	w := new(bytes.Buffer)
+	err := describe(context.Background(), newDescriptorSource(conn), grpcConfig{}, "grpc.health.v1.HealthCheckResponse.ServingStatus", w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(w.String(), expected); diff != "" {
+		t.Error(diff)
+	}
+}
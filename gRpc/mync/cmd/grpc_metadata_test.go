@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		pairs    []string
+		expected metadata.MD
+		err      error
+	}{
+		{
+			name:     "test1",
+			pairs:    []string{"Request-Id=abc", "tag=a", "tag=b=c"},
+			expected: metadata.MD{"request-id": {"abc"}, "tag": {"a", "b=c"}},
+		},
+		{
+			name:     "test2",
+			pairs:    []string{"trace-bin=AAEC", "raw-bin=AAE"},
+			expected: metadata.MD{"trace-bin": {"\x00\x01\x02"}, "raw-bin": {"\x00\x01"}},
+		},
+		{
+			name:  "test3",
+			pairs: []string{"request-id"},
+			err:   ErrInvalidMetadata,
+		},
+		{
+			name:  "test4",
+			pairs: []string{"trace-bin=not base64!"},
+			err:   ErrInvalidMetadata,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			md, err := parseMetadata(tc.pairs)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Expected error %v, got %v", tc.err, err)
+			}
+			if tc.err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.expected, md); diff != "" {
+				t.Errorf("Unexpected metadata (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// echoMetadata returns the request-id metadata in the response headers and
+// fails calls sending x-fail with a status carrying error details.
+func echoMetadata(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if strings.HasPrefix(info.FullMethod, "/grpc.reflection.") {
+		return handler(srv, ss)
+	}
+	md, _ := metadata.FromIncomingContext(ss.Context())
+	ss.SetHeader(metadata.MD{"request-id": md.Get("request-id")})
+	ss.SetTrailer(metadata.MD{"trace-bin": md.Get("trace-bin")})
+	if len(md.Get("x-fail")) != 0 {
+		st, err := status.New(codes.FailedPrecondition, "user is locked").WithDetails(
+			&errdetails.ErrorInfo{Reason: "LOCKED", Domain: "example.com"})
+		if err != nil {
+			return err
+		}
+		return st.Err()
+	}
+	return handler(srv, ss)
+}
+
+func TestCallMethodVerbose(t *testing.T) {
+	s, l := startTestStreamServer(grpc.StreamInterceptor(echoMetadata))
+	defer s.GracefulStop()
+	conn := dialTestServer(t, l)
+	defer conn.Close()
+
+	origStderr := stderr
+	defer func() { stderr = origStderr }()
+
+	tests := []struct {
+		name     string
+		c        grpcConfig
+		metadata []string
+		verbose  string
+		errMsg   string
+	}{
+		{
+			name:     "test1",
+			c:        grpcConfig{service: "Users", method: "GetUser", request: `{"email":"john@doe.com","id":"user-1"}`, verbose: true},
+			metadata: []string{"request-id=req-1", "trace-bin=AAEC"},
+			verbose: `Request metadata:
+  request-id: req-1
+  trace-bin: AAEC
+Response headers:
+  content-type: application/grpc
+  request-id: req-1
+Response trailers:
+  trace-bin: AAEC
+Status: OK (0)
+`,
+		},
+		{
+			name:     "test2",
+			c:        grpcConfig{service: "Users", method: "GetUser", request: `{"email":"john@doe.com","id":"user-1"}`, verbose: true},
+			metadata: []string{"x-fail=1"},
+			verbose: `Request metadata:
+  x-fail: 1
+Response headers:
+  content-type: application/grpc
+Response trailers:
+Status: FailedPrecondition (9)
+Message: user is locked
+Details:
+  {"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"LOCKED","domain":"example.com"}
+`,
+			errMsg: "user is locked",
+		},
+		{
+			name:     "test3",
+			c:        grpcConfig{service: "Repo", method: "CreateBuild", request: `{"name":"mync"}`, verbose: true},
+			metadata: []string{"request-id=req-2"},
+			verbose: `Request metadata:
+  request-id: req-2
+Response headers:
+  content-type: application/grpc
+  request-id: req-2
+Response trailers:
+Status: OK (0)
+status=OK sent=1 received=3
+`,
+		},
+		{
+			name:     "test4",
+			c:        grpcConfig{service: "Users", method: "GetUser", request: `{"email":"john@doe.com","id":"user-1"}`},
+			metadata: []string{"request-id=req-3"},
+			verbose:  "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			verbose := new(bytes.Buffer)
+			stderr = verbose
+			md, err := parseMetadata(tc.metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+			err = callMethod(ctx, conn, tc.c, new(bytes.Buffer))
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (err != nil && len(tc.errMsg) == 0) {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			// protojson randomly varies the spaces it prints.
+			got := strings.ReplaceAll(verbose.String(), `", "`, `","`)
+			if diff := cmp.Diff(tc.verbose, got); diff != "" {
+				t.Errorf("Unexpected verbose output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	svc "service"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// failWithStatus fails calls sending x-status metadata with that code.
+func failWithStatus(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("x-status"); len(values) != 0 {
+		code, err := strconv.Atoi(values[0])
+		if err != nil {
+			return nil, err
+		}
+		st, err := status.New(codes.Code(code), "failed on request").WithDetails(&errdetails.ErrorInfo{Reason: "TEST"})
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	}
+	return handler(ctx, req)
+}
+
+func TestHandleGrpcStatus(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := grpc.NewServer(grpc.UnaryInterceptor(failWithStatus))
+	defer s.Stop()
+	svc.RegisterUsersServer(s, &dummyUserService{})
+	go func() {
+		s.Serve(l)
+	}()
+
+	// A listener that accepts connections but never answers.
+	hung, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hung.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	call := []string{"-service", "Users", "-method", "GetUser", "-request", `{"email":"john@doe.com"}`}
+	tests := []struct {
+		name     string
+		args     []string
+		exitCode int
+		errMsg   string
+	}{
+		{
+			name:     "test1",
+			args:     append(call, l.Addr().String()),
+			exitCode: ExitOK,
+		},
+		{
+			name:     "test2",
+			args:     append(call, "-metadata", "x-status=5", l.Addr().String()),
+			exitCode: 15,
+			errMsg:   "rpc error: code = NotFound desc = failed on request",
+		},
+		{
+			name:     "test3",
+			args:     append(call, "-metadata", "x-status=3", "-error-format", "json", l.Addr().String()),
+			exitCode: 13,
+			errMsg:   `{"code":"InvalidArgument","codeNumber":3,"message":"failed on request","details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"TEST"}],"exitCode":13}`,
+		},
+		{
+			name:     "test4",
+			args:     append(call, "-deadline", "100ms", hung.Addr().String()),
+			exitCode: 14,
+			errMsg:   "DeadlineExceeded",
+		},
+		{
+			name:     "test5",
+			args:     append(call, closed.Addr().String()),
+			exitCode: 24,
+			errMsg:   "Unavailable",
+		},
+		{
+			name:     "test6",
+			args:     append(call, "-deadline", "-1s", l.Addr().String()),
+			exitCode: ExitError,
+			errMsg:   ErrInvalidDeadline.Error(),
+		},
+		{
+			name:     "test7",
+			args:     append(call, "-error-format", "xml", l.Addr().String()),
+			exitCode: ExitError,
+			errMsg:   ErrInvalidErrorFormat.Error(),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := HandleGrpc(new(bytes.Buffer), tc.args)
+			if code := ExitCode(err); code != tc.exitCode {
+				t.Errorf("Expected exit code %d, got %d (%v)", tc.exitCode, code, err)
+			}
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Errorf("Expected error %q, got %q", tc.errMsg, errMsg)
+			}
+		})
+	}
+
+	var se GrpcStatusError
+	err = HandleGrpc(new(bytes.Buffer), append(call, "-metadata", "x-status=7", l.Addr().String()))
+	if !errors.As(err, &se) || status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected a PermissionDenied GrpcStatusError, got %#v", err)
+	}
+}
@@ -0,0 +1,428 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The protos of gRpc/stream-service/service, which mync doesn't compile in.
+const streamUsersProto = `
+name: "users.proto"
+syntax: "proto3"
+message_type {
+  name: "UserGetRequest"
+  field { name: "email" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "id" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING }
+}
+message_type {
+  name: "User"
+  field { name: "id" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "first_name" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "last_name" number: 3 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "age" number: 4 label: LABEL_OPTIONAL type: TYPE_INT32 }
+}
+message_type {
+  name: "UserGetReply"
+  field { name: "user" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".User" }
+}
+message_type {
+  name: "UserHelpRequest"
+  field { name: "user" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".User" }
+  field { name: "request" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING }
+}
+message_type {
+  name: "UserHelpReply"
+  field { name: "response" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+}
+service {
+  name: "Users"
+  method { name: "GetUser" input_type: ".UserGetRequest" output_type: ".UserGetReply" }
+  method { name: "GetHelp" input_type: ".UserHelpRequest" output_type: ".UserHelpReply" client_streaming: true server_streaming: true }
+}
+`
+
+const streamReposProto = `
+name: "repositories.proto"
+syntax: "proto3"
+dependency: "users.proto"
+dependency: "google/protobuf/timestamp.proto"
+message_type {
+  name: "RepoGetRequest"
+  field { name: "id" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "creator_id" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+}
+message_type {
+  name: "Repository"
+  field { name: "id" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "name" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "url" number: 3 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "owner" number: 4 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".User" }
+}
+message_type {
+  name: "RepoGetReply"
+  field { name: "repo" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".Repository" }
+}
+message_type {
+  name: "RepoBuildLog"
+  field { name: "log_line" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "timestamp" number: 2 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" }
+}
+message_type {
+  name: "RepoCreateRequest"
+  field { name: "context" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".RepoContext" oneof_index: 0 }
+  field { name: "data" number: 2 label: LABEL_OPTIONAL type: TYPE_BYTES oneof_index: 0 }
+  oneof_decl { name: "body" }
+}
+message_type {
+  name: "RepoContext"
+  field { name: "creator_id" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+  field { name: "name" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING }
+}
+message_type {
+  name: "RepoCreateReply"
+  field { name: "repo" number: 1 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".Repository" }
+  field { name: "size" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 }
+}
+service {
+  name: "Repo"
+  method { name: "GetRepos" input_type: ".RepoGetRequest" output_type: ".RepoGetReply" server_streaming: true }
+  method { name: "CreateBuild" input_type: ".Repository" output_type: ".RepoBuildLog" server_streaming: true }
+  method { name: "CreateRepo" input_type: ".RepoCreateRequest" output_type: ".RepoCreateReply" client_streaming: true }
+}
+`
+
+func streamServiceFiles() (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+	err := files.RegisterFile(timestamppb.File_google_protobuf_timestamp_proto)
+	if err != nil {
+		return nil, err
+	}
+	for _, text := range []string{streamUsersProto, streamReposProto} {
+		fdp := &descriptorpb.FileDescriptorProto{}
+		err := prototext.Unmarshal([]byte(text), fdp)
+		if err != nil {
+			return nil, err
+		}
+		fd, err := protodesc.NewFile(fdp, files)
+		if err != nil {
+			return nil, err
+		}
+		err = files.RegisterFile(fd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// testStreamHandler serves one method of the stream service, messages are
+// converted to and from JSON to keep the handlers short.
+type testStreamHandler func(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error
+
+func recvJson(md protoreflect.MethodDescriptor, stream grpc.ServerStream, v any) error {
+	m := dynamicpb.NewMessage(md.Input())
+	err := stream.RecvMsg(m)
+	if err != nil {
+		return err
+	}
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func sendJson(md protoreflect.MethodDescriptor, stream grpc.ServerStream, format string, args ...any) error {
+	m := dynamicpb.NewMessage(md.Output())
+	err := protojson.Unmarshal([]byte(fmt.Sprintf(format, args...)), m)
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(m)
+}
+
+var testStreamHandlers = map[string]testStreamHandler{
+	"/Users/GetUser": func(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+		var in struct{ Email, Id string }
+		err := recvJson(md, stream, &in)
+		if err != nil {
+			return err
+		}
+		components := strings.Split(in.Email, "@")
+		if len(components) != 2 {
+			return status.Error(codes.InvalidArgument, "invalid email address")
+		}
+		return sendJson(md, stream, `{"user":{"id":%q,"firstName":%q,"lastName":%q,"age":36}}`, in.Id, components[0], components[1])
+	},
+	"/Users/GetHelp": func(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+		for {
+			var in struct{ Request string }
+			err := recvJson(md, stream, &in)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = sendJson(md, stream, `{"response":%q}`, in.Request)
+			if err != nil {
+				return err
+			}
+		}
+	},
+	"/Repo/GetRepos": func(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+		var in struct{ Id, CreatorId string }
+		err := recvJson(md, stream, &in)
+		if err != nil {
+			return err
+		}
+		for cnt := 1; cnt <= 5; cnt++ {
+			err := sendJson(md, stream, `{"repo":{"id":%q,"name":"repo-%d","owner":{"id":%q}}}`, in.Id, cnt, in.CreatorId)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	"/Repo/CreateBuild": func(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+		var in struct{ Name string }
+		err := recvJson(md, stream, &in)
+		if err != nil {
+			return err
+		}
+		for cnt := 1; cnt <= 3; cnt++ {
+			err := sendJson(md, stream, `{"logLine":"Build log line - %d","timestamp":"2024-01-01T00:00:0%dZ"}`, cnt, cnt)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	"/Repo/CreateRepo": func(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+		var name string
+		var size int
+		for {
+			var in struct {
+				Context *struct{ CreatorId, Name string }
+				Data    []byte
+			}
+			err := recvJson(md, stream, &in)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			switch {
+			case in.Context != nil:
+				name = in.Context.Name
+			case in.Data != nil:
+				size += len(in.Data)
+			default:
+				return status.Error(codes.InvalidArgument, "Message doesn't contain context or data")
+			}
+		}
+		return sendJson(md, stream, `{"repo":{"name":%q},"size":%d}`, name, size)
+	},
+}
+
+func registerTestStreamServices(s *grpc.Server, files *protoregistry.Files) error {
+	for _, name := range []string{"Users", "Repo"} {
+		desc, err := files.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return err
+		}
+		sd := desc.(protoreflect.ServiceDescriptor)
+		gsd := grpc.ServiceDesc{ServiceName: name, HandlerType: (*any)(nil)}
+		for i := 0; i < sd.Methods().Len(); i++ {
+			md := sd.Methods().Get(i)
+			handler := testStreamHandlers[methodPath(md)]
+			gsd.Streams = append(gsd.Streams, grpc.StreamDesc{
+				StreamName: string(md.Name()),
+				Handler: func(srv any, stream grpc.ServerStream) error {
+					return handler(md, stream)
+				},
+				ServerStreams: md.IsStreamingServer(),
+				ClientStreams: md.IsStreamingClient(),
+			})
+		}
+		s.RegisterService(&gsd, struct{}{})
+	}
+	return nil
+}
+
+// startTestStreamServer starts a server behaving like stream-service with
+// the reflection service registered.
+func startTestStreamServer(opts ...grpc.ServerOption) (*grpc.Server, *bufconn.Listener) {
+	files, err := streamServiceFiles()
+	if err != nil {
+		log.Fatal(err)
+	}
+	l := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer(opts...)
+	err = registerTestStreamServices(s, files)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rpb.RegisterServerReflectionServer(s, reflection.NewServerV1(reflection.ServerOptions{
+		Services:           s,
+		DescriptorResolver: files,
+	}))
+	go func() {
+		err := s.Serve(l)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	return s, l
+}
+
+func dialTestServer(t *testing.T, l *bufconn.Listener) *grpc.ClientConn {
+	bufconnDialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		return l.Dial()
+	}
+	conn, err := grpc.NewClient(
+		"passthrough://bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(bufconnDialer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestCallStreamingMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       grpcConfig
+		input   string
+		output  []string
+		summary string
+		errMsg  string
+	}{
+		{
+			name: "test1",
+			c:    grpcConfig{service: "Repo", method: "GetRepos", request: `{"id":"1","creatorId":"user-1"}`},
+			output: []string{
+				`{"repo":{"id":"1","name":"repo-1","owner":{"id":"user-1"}}}`,
+				`{"repo":{"id":"1","name":"repo-2","owner":{"id":"user-1"}}}`,
+				`{"repo":{"id":"1","name":"repo-3","owner":{"id":"user-1"}}}`,
+				`{"repo":{"id":"1","name":"repo-4","owner":{"id":"user-1"}}}`,
+				`{"repo":{"id":"1","name":"repo-5","owner":{"id":"user-1"}}}`,
+			},
+			summary: "status=OK sent=1 received=5\n",
+		},
+		{
+			name: "test2",
+			c:    grpcConfig{service: "Repo", method: "CreateBuild", request: `{"name":"mync"}`},
+			output: []string{
+				`{"logLine":"Build log line - 1","timestamp":"2024-01-01T00:00:01Z"}`,
+				`{"logLine":"Build log line - 2","timestamp":"2024-01-01T00:00:02Z"}`,
+				`{"logLine":"Build log line - 3","timestamp":"2024-01-01T00:00:03Z"}`,
+			},
+			summary: "status=OK sent=1 received=3\n",
+		},
+		{
+			name:    "test3",
+			c:       grpcConfig{service: "Repo", method: "CreateRepo"},
+			input:   "{\"context\":{\"creatorId\":\"user-1\",\"name\":\"mync\"}}\n\n{\"data\":\"aGVsbG8=\"}\n{\"data\":\"IQ==\"}\n",
+			output:  []string{`{"repo":{"name":"mync"},"size":6}`},
+			summary: "status=OK sent=3 received=1\n",
+		},
+		{
+			name:    "test4",
+			c:       grpcConfig{service: "Users", method: "GetHelp"},
+			input:   "{\"request\":\"help\"}\n{\"request\":\"more help\"}\n",
+			output:  []string{`{"response":"help"}`, `{"response":"more help"}`},
+			summary: "status=OK sent=2 received=2\n",
+		},
+		{
+			name:    "test5",
+			c:       grpcConfig{service: "Repo", method: "CreateRepo"},
+			input:   "{}\n",
+			output:  []string{},
+			summary: "status=InvalidArgument sent=1 received=0\n",
+			errMsg:  "Message doesn't contain context or data",
+		},
+		{
+			name:   "test6",
+			c:      grpcConfig{service: "Users", method: "GetHelp"},
+			input:  "{\"request\":\"help\"}\nfoo-bar\n",
+			errMsg: "request on line 2",
+		},
+		{
+			name:    "test7",
+			c:       grpcConfig{service: "Users", method: "GetUser", requestFile: "-"},
+			input:   "{\"email\":\"john@doe.com\",\"id\":\"user-1\"}\n",
+			output:  []string{`{"index":0,"response":{"user":{"id":"user-1","firstName":"john","lastName":"doe.com","age":36}}}`},
+			summary: "",
+		},
+	}
+
+	s, l := startTestStreamServer()
+	defer s.GracefulStop()
+	conn := dialTestServer(t, l)
+	defer conn.Close()
+
+	origStdin, origStderr := stdin, stderr
+	defer func() { stdin, stderr = origStdin, origStderr }()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			summary := new(bytes.Buffer)
+			stdin, stderr = strings.NewReader(tc.input), summary
+
+			err := callMethod(context.Background(), conn, tc.c, w)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if tc.output == nil {
+				return
+			}
+
+			lines := strings.Split(strings.TrimSpace(strings.Replace(w.String(), " ", "", -1)), "\n")
+			if len(tc.output) == 0 && lines[0] == "" {
+				lines = lines[:0]
+			}
+			if len(lines) != len(tc.output) {
+				t.Fatalf("Expected %d lines, got: %#v", len(tc.output), lines)
+			}
+			for num := range tc.output {
+				if lines[num] != strings.Replace(tc.output[num], " ", "", -1) {
+					t.Errorf("Expected output line to be: %v, Got: %v", tc.output[num], lines[num])
+				}
+			}
+			if summary.String() != tc.summary {
+				t.Errorf("Expected summary: %q, Got: %q", tc.summary, summary.String())
+			}
+		})
+	}
+}
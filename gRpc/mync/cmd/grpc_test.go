@@ -13,6 +13,9 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/test/bufconn"
 )
 
@@ -71,19 +74,19 @@ func Test_callUserSvc(t *testing.T) {
 	}{
 		{
 			name:     "test1",
-			c:        grpcConfig{},
+			c:        grpcConfig{service: "Users"},
 			respJson: "",
 			errMsg:   ErrInvalidGrpcMethod.Error(),
 		},
 		{
 			name:     "test2",
-			c:        grpcConfig{method: "GetUser", request: `{"email":"john@doe.com","id":"user-123"}`},
+			c:        grpcConfig{service: "Users", method: "GetUser", request: `{"email":"john@doe.com","id":"user-123"}`},
 			errMsg:   "",
 			respJson: `{"user":{"id":"user-123","firstName":"john","lastName":"doe.com","age":36}}`,
 		},
 		{
 			name:     "test3",
-			c:        grpcConfig{method: "GetUser", request: "foo-bar"},
+			c:        grpcConfig{service: "Users", method: "GetUser", request: "foo-bar"},
 			errMsg:   "invalid value",
 			respJson: "",
 		},
@@ -107,8 +110,8 @@ func Test_callUserSvc(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			usersClient := getUserServiceClient(conn)
-			respJson, err := callUserMethod(usersClient, tc.c)
+			w := new(bytes.Buffer)
+			err := callMethod(context.Background(), conn, tc.c, w)
 			var errMsg string
 			if err != nil {
 				errMsg = err.Error()
@@ -117,7 +120,7 @@ func Test_callUserSvc(t *testing.T) {
 				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
 			}
 
-			sanitizedRespJson := strings.Replace(string(respJson), " ", "", -1)
+			sanitizedRespJson := strings.Replace(w.String(), " ", "", -1)
 			sanitizedRespJson = strings.Replace(string(sanitizedRespJson), "\n", "", -1)
 
 			if sanitizedRespJson != tc.respJson {
@@ -137,19 +140,19 @@ func TestCallReposSvc(t *testing.T) {
 	}{
 		{
 			name:     "test1",
-			c:        grpcConfig{},
+			c:        grpcConfig{service: "Repo"},
 			respJson: "",
 			errMsg:   ErrInvalidGrpcMethod.Error(),
 		},
 		{
 			name:     "test2",
-			c:        grpcConfig{method: "GetRepos", request: `{"id":"1"}`},
+			c:        grpcConfig{service: "Repo", method: "GetRepos", request: `{"id":"1"}`},
 			errMsg:   "",
 			respJson: `{"repo":[{"id":"repo-123","name":"hsh","url":"github.com","owner":{"id":"user-123"}}]}`,
 		},
 		{
 			name:     "test3",
-			c:        grpcConfig{method: "GetRepos", request: "foo-bar"},
+			c:        grpcConfig{service: "Repo", method: "GetRepos", request: "foo-bar"},
 			errMsg:   "invalid value",
 			respJson: "",
 		},
@@ -172,8 +175,8 @@ func TestCallReposSvc(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			repoClient := getRepoServiceClient(conn)
-			respJson, err := callRepoMethod(repoClient, tc.c)
+			w := new(bytes.Buffer)
+			err := callMethod(context.Background(), conn, tc.c, w)
 			var errMsg string
 			if err != nil {
 				errMsg = err.Error()
@@ -182,7 +185,7 @@ func TestCallReposSvc(t *testing.T) {
 				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
 			}
 
-			sanitizedRespJson := strings.Replace(string(respJson), " ", "", -1)
+			sanitizedRespJson := strings.Replace(w.String(), " ", "", -1)
 			sanitizedRespJson = strings.Replace(string(sanitizedRespJson), "\n", "", -1)
 
 			if sanitizedRespJson != tc.respJson {
@@ -193,6 +196,98 @@ func TestCallReposSvc(t *testing.T) {
 	}
 }
 
+func startTestReflectionServer() (*grpc.Server, *bufconn.Listener) {
+	l := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	svc.RegisterUsersServer(s, &dummyUserService{})
+	svc.RegisterRepoServer(s, &dummyReposService{})
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	reflection.Register(s)
+	go func() {
+		err := s.Serve(l)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+	return s, l
+}
+
+func TestCallMethodReflection(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        grpcConfig
+		respJson string
+		errMsg   string
+	}{
+		{
+			name:     "test1",
+			c:        grpcConfig{service: "grpc.health.v1.Health", method: "Check", request: "{}"},
+			errMsg:   "",
+			respJson: `{"status":"SERVING"}`,
+		},
+		{
+			name:     "test2",
+			c:        grpcConfig{service: "Health", method: "Check"},
+			errMsg:   "",
+			respJson: `{"status":"SERVING"}`,
+		},
+		{
+			name:     "test3",
+			c:        grpcConfig{service: "Users", method: "GetUser", request: `{"email":"john@doe.com","id":"user-123"}`},
+			errMsg:   "",
+			respJson: `{"user":{"id":"user-123","firstName":"john","lastName":"doe.com","age":36}}`,
+		},
+		{
+			name:     "test4",
+			c:        grpcConfig{service: "Gopher", method: "Hello", request: "{}"},
+			errMsg:   ErrUnrecognizedService.Error(),
+			respJson: "",
+		},
+		{
+			name:     "test5",
+			c:        grpcConfig{service: "grpc.health.v1.Health", method: "Check", request: `{"service":1}`},
+			errMsg:   "invalid value",
+			respJson: "",
+		},
+	}
+	s, l := startTestReflectionServer()
+	defer s.GracefulStop()
+
+	bufconnDialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		return l.Dial()
+	}
+
+	conn, err := grpc.NewClient(
+		"passthrough://bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(bufconnDialer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := callMethod(context.Background(), conn, tc.c, w)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+
+			sanitizedRespJson := strings.Replace(w.String(), " ", "", -1)
+			sanitizedRespJson = strings.Replace(string(sanitizedRespJson), "\n", "", -1)
+
+			if sanitizedRespJson != tc.respJson {
+				t.Fatalf("Expected result: %v Got: %v", tc.respJson, sanitizedRespJson)
+			}
+		})
+	}
+}
+
 func TestHandleGrpc(t *testing.T) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -252,6 +347,20 @@ func TestHandleGrpc(t *testing.T) {
 			output:   "",
 			respJson: `{"repo":[{"id":"repo-123","name":"hsh","url":"github.com","owner":{"id":"user-123"}}]}`,
 		},
+		{
+			name:     "test6",
+			args:     []string{"list", l.Addr().String()},
+			errMsg:   "",
+			output:   "Repo\nUsers\n",
+			respJson: "",
+		},
+		{
+			name:     "test7",
+			args:     []string{"describe", "-service", "Repo", "-method", "GetRepos", l.Addr().String()},
+			errMsg:   "",
+			output:   "// Repo.GetRepos (repositories.proto)\nrpc GetRepos(RepoGetRequest) returns (RepoGetReply);\n\n// RepoGetRequest (repositories.proto)\nmessage RepoGetRequest {\n  string id = 2;\n  string creator_id = 1;\n}\n\n// RepoGetReply (repositories.proto)\nmessage RepoGetReply {\n  repeated Repository repo = 1;\n}\n\n// Repository (repositories.proto)\nmessage Repository {\n  string id = 1;\n  string name = 2;\n  string url = 3;\n  User owner = 4;\n}\n\n// User (users.proto)\nmessage User {\n  string id = 1;\n  string first_name = 2;\n  string last_name = 3;\n  int32 age = 4;\n}\n",
+			respJson: "",
+		},
 	}
 
 	w := new(bytes.Buffer)
@@ -292,16 +401,66 @@ func TestGrpcCmdFlagParsing(t *testing.T) {
 grpc: A gRPC client.
  
 grpc: <options> server
+grpc: list <options> server
+grpc: describe <options> server [symbol]
 
 Options:
+  -bench
+    	Load test the server and print a summary instead of the responses
+  -bench-format string
+    	Format of the bench mode summary (text or json) (default "text")
+  -cacert string
+    	CA certificate file (PEM) used to verify the server
+  -cert string
+    	Client certificate file (PEM) for mutual TLS
+  -concurrency int
+    	Number of concurrent calls in bench mode or for unary methods with -request-file (default 1)
+  -connections int
+    	Number of connections to spread the calls over in bench mode (default 1)
+  -deadline duration
+    	Deadline of each call, e.g. 5s (no deadline by default)
+  -duration duration
+    	Duration of the load test in bench mode, overrides num-calls unless it is also set
+  -error-format string
+    	Format of errors with a gRPC status (text or json) (default "text")
+  -insecure-skip-verify
+    	Do not verify the server's TLS certificate
+  -key string
+    	Client private key file (PEM) for mutual TLS
+  -metadata value
+    	Add one or more metadata entries to the call (key=value, base64 values for -bin keys)
   -method string
     	Method to call
+  -num-calls int
+    	Number of calls to make in bench mode (default 1)
   -pretty-print
     	Pretty print the JSON output
+  -profile string
+    	Named profile in the config file to take defaults from, overridden by flags
+  -query string
+    	Print the values selected from each JSON response, e.g. .repo[0].owner.id
+  -rate float
+    	Maximum number of calls per second in bench mode (0 for no limit)
   -request string
     	Request to send
+  -request-file string
+    	File with one JSON request per line (- for stdin), sent as a stream or as one unary call each
+  -server-name string
+    	Name to verify the server's TLS certificate against
   -service string
     	gRpc service to send the request to
+  -verbose
+    	Print the request metadata, response headers and trailers and the status on stderr
+
+Exit codes:
+  0     The call succeeded
+  1     Invalid usage or an error without a gRPC status
+  10+N  The call failed with gRPC status code N:
+        11 Canceled, 12 Unknown, 13 InvalidArgument, 14 DeadlineExceeded,
+        15 NotFound, 16 AlreadyExists, 17 PermissionDenied,
+        18 ResourceExhausted, 19 FailedPrecondition, 20 Aborted,
+        21 OutOfRange, 22 Unimplemented, 23 Internal, 24 Unavailable,
+        25 DataLoss, 26 Unauthenticated
 `
 	tests := []struct {
 		name   string
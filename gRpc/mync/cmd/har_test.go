@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHandleHttpHar(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/users", http.StatusFound)
+	})
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Version", fmt.Sprint(version.Load()))
+		fmt.Fprintf(w, `{"id":%d,"name":"jane","echo":%q}`, version.Load(), body)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	harFile := filepath.Join(t.TempDir(), "out.har")
+	w := new(bytes.Buffer)
+	err := HandleHttp(w, []string{"-har", harFile, "-header", "X-Trace=1", ts.URL + "/old?page=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = HandleHttp(new(bytes.Buffer), []string{"-verb", "PUT", "-body", `{"a":1}`, "-har", harFile, ts.URL + "/users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	har, err := readHar(harFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || har.Log.Creator.Name != "mync" {
+		t.Errorf("Unexpected HAR log: %+v", har.Log)
+	}
+	// Every invocation writes its own requests, the PUT replaced the GET.
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(har.Log.Entries))
+	}
+	put := har.Log.Entries[0]
+	if put.Request.Method != "PUT" || put.Request.PostData == nil || put.Request.PostData.Text != `{"a":1}` ||
+		put.Request.PostData.MimeType != "application/json" {
+		t.Errorf("Unexpected PUT request: %+v", put.Request)
+	}
+	if put.Response.Status != 200 || !strings.Contains(put.Response.Content.Text, `"echo":"{\"a\":1}"`) {
+		t.Errorf("Unexpected PUT response: %+v", put.Response)
+	}
+
+	err = HandleHttp(new(bytes.Buffer), []string{"-har", harFile, "-header", "X-Trace=1", ts.URL + "/old?page=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	har, err = readHar(harFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("Expected the redirect and the request it led to, got %d entries", len(har.Log.Entries))
+	}
+	redirect, users := har.Log.Entries[0], har.Log.Entries[1]
+	if redirect.Response.Status != http.StatusFound || redirect.Response.RedirectURL != "/users" {
+		t.Errorf("Unexpected redirect response: %+v", redirect.Response)
+	}
+	if diff := cmp.Diff(`[{page 2}]`, fmt.Sprint(redirect.Request.QueryString)); diff != "" {
+		t.Errorf("Unexpected query string (-want +got):\n%s", diff)
+	}
+	var traced bool
+	for _, h := range redirect.Request.Headers {
+		traced = traced || (h.Name == "X-Trace" && h.Value == "1")
+	}
+	if !traced {
+		t.Errorf("Expected the X-Trace header in the request, got: %+v", redirect.Request.Headers)
+	}
+	if users.Request.URL != ts.URL+"/users" || users.Response.Content.MimeType != "application/json" {
+		t.Errorf("Unexpected redirected entry: %+v", users)
+	}
+	if users.Time <= 0 || users.Timings.Wait < 0 || users.Timings.Receive < 0 {
+		t.Errorf("Unexpected timings: %v %+v", users.Time, users.Timings)
+	}
+
+	w = new(bytes.Buffer)
+	err = HandleHttp(w, []string{"replay", harFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf(`GET %[1]s/old?page=2: unchanged
+GET %[1]s/users: unchanged
+Replayed 2 requests, 0 differ
+`, ts.URL)
+	if diff := cmp.Diff(expected, w.String()); diff != "" {
+		t.Errorf("Unexpected replay output (-want +got):\n%s", diff)
+	}
+
+	version.Store(2)
+	w = new(bytes.Buffer)
+	err = HandleHttp(w, []string{"replay", harFile})
+	if !errors.Is(err, ErrReplayMismatch) {
+		t.Fatalf("Expected ErrReplayMismatch, got %v", err)
+	}
+	expected = fmt.Sprintf(`GET %[1]s/old?page=2: unchanged
+GET %[1]s/users: differs
+  header X-Version: "1" -> "2"
+  body:
+    -   "id": 1,
+    +   "id": 2,
+Replayed 2 requests, 1 differ
+`, ts.URL)
+	if diff := cmp.Diff(expected, w.String()); diff != "" {
+		t.Errorf("Unexpected replay output (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandleHttpHarInvalid(t *testing.T) {
+	harFile := filepath.Join(t.TempDir(), "invalid.har")
+	err := os.WriteFile(harFile, []byte("not a HAR"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		err  error
+	}{
+		{name: "test1", args: []string{"replay"}, err: ErrNoHarFile},
+		{name: "test2", args: []string{"replay", "-bench", harFile}, err: ErrInvalidReplay},
+		{name: "test3", args: []string{"replay", "-har", "out.har", harFile}, err: ErrInvalidReplay},
+		{name: "test4", args: []string{"-bench", "-har", "out.har", "http://localhost"}, err: ErrInvalidBenchHar},
+		{name: "test5", args: []string{"replay", harFile}, err: ErrInvalidHar},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := HandleHttp(new(bytes.Buffer), tc.args)
+			if err == nil || !strings.HasPrefix(err.Error(), tc.err.Error()) {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines([]string{"a", "b", "c", "d"}, []string{"a", "c", "e", "d"})
+	expected := []string{"- b", "+ e"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected diff (-want +got):\n%s", diff)
+	}
+}
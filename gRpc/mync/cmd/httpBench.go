@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// benchHttp sends fresh requests built from c according to the load
+// options and writes a summary of the status codes, errors and latencies.
+func benchHttp(w io.Writer, client *http.Client, c httpConfig) error {
+	stats := newLoadStats()
+	runLoad(c.bench.load, func(int) {
+		start := time.Now()
+		code, err := benchHttpRequest(client, c)
+		stats.record(time.Since(start), code, err)
+	})
+	stats.stop()
+	return writeLoadSummary(w, stats.summary(), c.bench.format)
+}
+
+func benchHttpRequest(client *http.Client, c httpConfig) (string, error) {
+	r, err := sendRequest(client, c)
+	if err != nil {
+		return "", err
+	}
+	defer r.Body.Close()
+	_, err = io.Copy(io.Discard, r.Body)
+	return strconv.Itoa(r.StatusCode), err
+}
@@ -10,26 +10,57 @@ import (
 	"mync/middleware"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"text/template"
 	"time"
 )
 
 type httpConfig struct {
 	url             string
 	verb            string
-	postBody        string
+	body            string
+	contentType     string
+	parts           []multipartPart
 	disableRedirect bool
 	headers         []string
 	basicAuth       string
 	report          bool
+	reportFormat    string
+	include         bool
+	prettyPrint     bool
+	fail            bool
+	writeOut        *template.Template
+	query           *jsonQuery
+	extraHeaders    http.Header
+	parallel        int
+	sha256          string
 	numRequests     int
 	maxIdleConns    int
+	timeout         time.Duration
+	retries         int
+	retryBackoff    time.Duration
+	retryOn         string
+	proxy           string
+	unixSocket      string
+	resolve         []string
+	tls             tlsOptions
+	bench           benchOptions
+	// headerTimeout limits c.timeout to waiting for the response headers,
+	// so that large downloads can take as long as they need.
+	headerTimeout bool
+	// observe, if set, is called with every response printed.
+	observe func(r *http.Response, body []byte)
 }
 
+// bodyVerbs are the methods a request body can be sent with.
+var bodyVerbs = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
 func validateConfig(c httpConfig) error {
 	var validMethod bool
-	allowVerbs := []string{http.MethodGet, http.MethodPost, http.MethodHead}
+	allowVerbs := append([]string{http.MethodGet, http.MethodHead, http.MethodOptions}, bodyVerbs...)
 	for _, v := range allowVerbs {
 		if c.verb == v {
 			validMethod = true
@@ -39,53 +70,236 @@ func validateConfig(c httpConfig) error {
 		return ErrInvalidHTTPMethod
 	}
 
-	if c.verb == http.MethodPost && c.postBody == "" {
+	hasBody := c.body != "" || len(c.parts) != 0
+	if c.verb == http.MethodPost && !hasBody {
 		return ErrInvalidHTTPPostRequest
 	}
 
-	if c.verb != http.MethodPost && c.postBody != "" {
+	if hasBody && !slices.Contains(bodyVerbs, c.verb) {
 		return ErrInvalidHTTPCommand
 	}
 
+	if c.contentType != "" && !hasBody {
+		return ErrInvalidContentType
+	}
+
+	if c.contentType != "" && len(c.parts) != 0 {
+		return ErrInvalidMultipartContentType
+	}
+
+	if c.timeout <= 0 {
+		return ErrInvalidTimeout
+	}
+
+	if c.reportFormat != "text" && c.reportFormat != "json" {
+		return ErrInvalidReportFormat
+	}
+
+	if c.retries < 0 || c.retryBackoff < 0 {
+		return ErrInvalidRetries
+	}
+
 	return nil
 }
 
 func addHeaders(c httpConfig, req *http.Request) {
 	for _, h := range c.headers {
-		kv := strings.Split(h, "=")
-		req.Header.Add(kv[0], kv[1])
+		k, v, _ := strings.Cut(h, "=")
+		req.Header.Add(k, v)
 	}
 }
 
 func addBasicAuth(c httpConfig, req *http.Request) {
 	if len(c.basicAuth) != 0 {
-		up := strings.Split(c.basicAuth, "=")
-		req.SetBasicAuth(up[0], up[1])
+		user, password, _ := strings.Cut(c.basicAuth, "=")
+		req.SetBasicAuth(user, password)
+	}
+}
+
+// newRequest builds a fresh request from c, so that the body can be sent
+// again by every one of several requests.
+func newRequest(ctx context.Context, c httpConfig) (*http.Request, error) {
+	var body io.Reader
+	if c.body != "" {
+		body = strings.NewReader(c.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, c.verb, c.url, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.body != "" {
+		contentType := c.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	if len(c.parts) != 0 {
+		setMultipartBody(req, c.parts)
+	}
+	for k, v := range c.extraHeaders {
+		req.Header[k] = v
+	}
+	addHeaders(c, req)
+	addBasicAuth(c, req)
+	return req, nil
+}
+
+// sendRequest sends a new request built from c. The request times out
+// after c.timeout, including reading the response body unless
+// c.headerTimeout is set. With retries the timeout applies to every attempt
+// and is enforced by the retry middleware.
+func sendRequest(client *http.Client, c httpConfig) (*http.Response, error) {
+	return sendRequestContext(context.Background(), client, c)
+}
+
+// sendRequestContext is sendRequest with a parent context that can cancel
+// the request early.
+func sendRequestContext(parent context.Context, client *http.Client, c httpConfig) (*http.Response, error) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var headerTimer *time.Timer
+	switch {
+	case c.retries > 0:
+		// The retry middleware times out every attempt instead.
+		ctx, cancel = context.WithCancel(parent)
+	case c.headerTimeout:
+		ctx, cancel = context.WithCancel(parent)
+		headerTimer = time.AfterFunc(c.timeout, cancel)
+	default:
+		ctx, cancel = context.WithTimeout(parent, c.timeout)
+	}
+	req, err := newRequest(ctx, c)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	r, err := client.Do(req)
+	if headerTimer != nil && !headerTimer.Stop() && parent.Err() == nil {
+		if err == nil {
+			r.Body.Close()
+		}
+		cancel()
+		return nil, middleware.ErrResponseHeaderTimeout
+	}
+	if err != nil {
+		cancel()
+		return nil, tlsError(err)
+	}
+	r.Body = cancelOnClose{ReadCloser: r.Body, cancel: cancel}
+	return r, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// readBodyFile reads a request body from path, or from stdin for -.
+func readBodyFile(path string) (string, error) {
+	if path == "-" {
+		data, err := io.ReadAll(stdin)
+		return string(data), err
 	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// encodeForm encodes key=value fields as a form-urlencoded body, keeping
+// the order they were given in.
+func encodeForm(fields []string) (string, error) {
+	var parts []string
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok || k == "" {
+			return "", fmt.Errorf("%w: %q", ErrInvalidFormField, f)
+		}
+		parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+	}
+	return strings.Join(parts, "&"), nil
 }
 
 func HandleHttp(w io.Writer, args []string) error {
+	return handleHttp(w, args, nil)
+}
+
+// handleHttp is HandleHttp calling observe with every response it prints.
+func handleHttp(w io.Writer, args []string, observe func(*http.Response, []byte)) error {
 	var outputFile string
-	var postBodyFile string
-	var responseBody []byte
-	var req *http.Request
+	var writeOutText string
+	var queryText string
+	var cookieJarFile string
+	var profileName string
+	var harFile string
+	var fromCurl string
+	var printCurl bool
+	var bodyFile string
+	var formFields []string
+	var multipartForm, multipartFiles []string
 	var httpClient http.Client
-	var ctx context.Context
 	var redirectPolicyFunc func(req *http.Request, via []*http.Request) error
-	c := httpConfig{}
+	c := httpConfig{timeout: 200 * time.Millisecond, observe: observe}
+
+	var replay bool
+	if len(args) > 0 && args[0] == "replay" {
+		replay, args = true, args[1:]
+	}
 
 	fs := flag.NewFlagSet("http", flag.ContinueOnError)
 	fs.SetOutput(w)
 	fs.StringVar(&c.verb, "verb", "GET", "HTTP method")
 	fs.StringVar(&outputFile, "output", "", "File path to write the response into")
-	fs.StringVar(&c.postBody, "body", "", "JSON data for HTTP POST request")
-	fs.StringVar(&postBodyFile, "body-file", "", "File containing JSON data for HTTP POST request")
+	fs.IntVar(&c.parallel, "parallel", 1, "Number of byte ranges to download concurrently with -output")
+	fs.StringVar(&c.sha256, "sha256", "", "Expected SHA-256 digest (hex) of the file downloaded with -output")
+	fs.StringVar(&c.body, "body", "", "Request body, sent as JSON unless -content-type is set")
+	fs.StringVar(&bodyFile, "body-file", "", "File containing the request body (- for stdin)")
+	fs.StringVar(&c.contentType, "content-type", "", "Content type of the request body, JSON or form-urlencoded by default")
+	fs.DurationVar(&c.timeout, "timeout", c.timeout, "Timeout of each request, including reading the response unless it is saved with -output")
 	fs.BoolVar(&c.disableRedirect, "disable-redirect", false, "Do not follow redirection request")
+	fs.StringVar(&cookieJarFile, "cookie-jar", "", "File to load cookies from and save them to (Netscape cookies.txt format)")
+	fs.StringVar(&fromCurl, "from-curl", "", "curl command to take the request from, overridden by flags")
+	fs.BoolVar(&printCurl, "print-curl", false, "Print the request as a curl command instead of sending it")
+	fs.StringVar(&harFile, "har", "", "File to record the requests and responses into (HAR 1.2 format)")
+	fs.StringVar(&profileName, "profile", "", "Named profile in the config file to take defaults from, overridden by flags")
 	fs.StringVar(&c.basicAuth, "basicAuth", "", "Add basic auth (username:password) credentials to the outgoing request")
 	fs.BoolVar(&c.report, "report", false, "report this http request's latency")
+	fs.StringVar(&c.reportFormat, "report-format", "text", "Format of the latency report (text or json)")
+	fs.BoolVar(&c.include, "include", false, "Print the response status line and headers before the body")
+	fs.BoolVar(&c.prettyPrint, "pretty-print", false, "Pretty print JSON response bodies")
+	fs.BoolVar(&c.fail, "fail", false, "Exit with an error if the server responds with a 4xx or 5xx status")
+	fs.StringVar(&writeOutText, "write-out", "", "Template printed after each response, e.g. '{{.StatusCode}} {{.Latency}}'")
+	fs.StringVar(&queryText, "query", "", "Print the values selected from the JSON response body, e.g. .repo[0].owner.id")
 	fs.IntVar(&c.numRequests, "num-requests", 1, "Number of requests to make")
 	fs.IntVar(&c.maxIdleConns, "max-idle-conns", 0, "Maximum number of idle connections for the connection pool")
+	fs.IntVar(&c.retries, "retries", 0, "Number of times to retry a failed request")
+	fs.DurationVar(&c.retryBackoff, "retry-backoff", 100*time.Millisecond, "Delay before the first retry, doubled for every further retry")
+	fs.StringVar(&c.retryOn, "retry-on", "5xx,429,connection", "Comma separated status codes (e.g. 503 or 5xx) and connection errors to retry on")
+	fs.StringVar(&c.proxy, "proxy", "", "Proxy URL (http, https or socks5), instead of the proxy of the environment")
+	fs.StringVar(&c.unixSocket, "unix-socket", "", "Unix socket to connect to instead of the host of the URL")
+	fs.Func("resolve", "Connect to addr instead of host:port, e.g. example.com:443:127.0.0.1 (host:port:addr)", func(s string) error {
+		c.resolve = append(c.resolve, s)
+		return nil
+	})
+	addBenchFlags(fs, &c.bench, "requests")
+	addTLSFlags(fs, &c.tls)
 
+	fs.Func("data-urlencode", "Add a field to a form-urlencoded request body (key=value)", func(s string) error {
+		formFields = append(formFields, s)
+		return nil
+	})
+	fs.Func("file", "Add a file to a multipart/form-data request body (field=@path)", func(s string) error {
+		multipartFiles = append(multipartFiles, s)
+		return nil
+	})
+	fs.Func("form", "Add a field to a multipart/form-data request body (name=value)", func(s string) error {
+		multipartForm = append(multipartForm, s)
+		return nil
+	})
 	fs.Func("header", "Add one or more headers to the outgoing request (key=value)", func(s string) error {
 		c.headers = append(c.headers, s)
 		return nil
@@ -95,7 +309,8 @@ func HandleHttp(w io.Writer, args []string) error {
 		var usageString = `
 http: A HTTP client.
  
-http: <options> server`
+http: <options> server
+http: replay <options> file.har`
 		fmt.Fprint(w, usageString)
 
 		fmt.Fprintln(w)
@@ -109,28 +324,137 @@ http: <options> server`
 		return FlagParsingError{err}
 	}
 
-	if fs.NArg() != 1 {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var curlURL string
+	if len(fromCurl) != 0 {
+		curlURL, err = applyCurl(fs, set, c.headers, fromCurl)
+		if err != nil {
+			return InvalidInputError{err}
+		}
+	}
+
+	var baseURL string
+	if len(profileName) != 0 {
+		p, err := loadProfile(profileName)
+		if err != nil {
+			return err
+		}
+		err = p.apply(fs, set, map[string]*[]string{"header": &c.headers})
+		if err != nil {
+			return err
+		}
+		baseURL, _ = p.get("url")
+	}
+
+	if replay {
+		if fs.NArg() != 1 {
+			return InvalidInputError{ErrNoHarFile}
+		}
+		if c.bench.enabled || outputFile != "" || harFile != "" || printCurl {
+			return InvalidInputError{ErrInvalidReplay}
+		}
+	} else if fs.NArg() > 1 || (fs.NArg() == 0 && len(baseURL) == 0 && len(curlURL) == 0) {
 		return InvalidInputError{ErrNoServerSpecified}
 	}
+	if c.bench.enabled && harFile != "" {
+		return InvalidInputError{ErrInvalidBenchHar}
+	}
+	if c.bench.enabled && printCurl {
+		return InvalidInputError{ErrInvalidBenchPrintCurl}
+	}
 
-	if postBodyFile != "" && c.postBody != "" {
+	var bodySources int
+	multipart := len(multipartForm) != 0 || len(multipartFiles) != 0
+	for _, given := range []bool{set["body"], bodyFile != "", len(formFields) != 0, multipart} {
+		if given {
+			bodySources++
+		}
+	}
+	if bodySources > 1 {
 		return InvalidInputError{ErrInvalidHTTPPostCommand}
 	}
 
-	if c.verb == http.MethodPost && postBodyFile != "" {
-		data, err := os.ReadFile(postBodyFile)
+	if bodyFile != "" {
+		c.body, err = readBodyFile(bodyFile)
 		if err != nil {
 			return err
 		}
-		c.postBody = string(data)
+	}
+	if len(formFields) != 0 {
+		c.body, err = encodeForm(formFields)
+		if err != nil {
+			return InvalidInputError{err}
+		}
+		if c.contentType == "" {
+			c.contentType = "application/x-www-form-urlencoded"
+		}
+	}
+	if multipart {
+		c.parts, err = parseMultipartParts(multipartForm, multipartFiles)
+		if err != nil {
+			return InvalidInputError{err}
+		}
+	}
+
+	c.writeOut, err = parseWriteOut(writeOutText)
+	if err != nil {
+		return InvalidInputError{err}
+	}
+
+	c.query, err = parseQuery(queryText)
+	if err != nil {
+		return InvalidInputError{err}
 	}
 
 	err = validateConfig(c)
 	if err != nil {
 		return InvalidInputError{err}
 	}
+	err = validateBenchOptions(c.bench, set)
+	if err != nil {
+		return InvalidInputError{err}
+	}
+	if c.bench.enabled && outputFile != "" {
+		return InvalidInputError{ErrInvalidBenchOutput}
+	}
+	err = validateDownload(c, outputFile)
+	if err != nil {
+		return InvalidInputError{err}
+	}
+	err = validateTLSOptions(c.tls)
+	if err != nil {
+		return InvalidInputError{err}
+	}
+	retryOn, err := parseRetryOn(c.retryOn)
+	if err != nil {
+		return InvalidInputError{err}
+	}
+	tlsConfig, err := c.tls.config()
+	if err != nil {
+		return err
+	}
+	proxy, err := parseProxy(c.proxy)
+	if err != nil {
+		return InvalidInputError{err}
+	}
+	resolve, err := parseResolve(c.resolve)
+	if err != nil {
+		return InvalidInputError{err}
+	}
+	if len(c.unixSocket) != 0 && (len(c.proxy) != 0 || len(resolve) != 0) {
+		return InvalidInputError{ErrInvalidUnixSocket}
+	}
 
-	c.url = fs.Arg(0)
+	target := fs.Arg(0)
+	if len(target) == 0 {
+		target = curlURL
+	}
+	c.url = resolveURL(baseURL, target)
+	if printCurl {
+		return writeCurl(w, c, set, bodyFile, outputFile)
+	}
 
 	if c.disableRedirect {
 		redirectPolicyFunc = func(req *http.Request, via []*http.Request) error {
@@ -141,79 +465,138 @@ http: <options> server`
 		}
 	}
 	t := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
+		Proxy: proxy,
+		DialContext: dialContext(&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
-		}).DialContext,
+		}, c.unixSocket, resolve),
 		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          c.maxIdleConns,
 		IdleConnTimeout:       90 * time.Second,
+		TLSClientConfig:       tlsConfig,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
-	httpLatencyMiddleWare := middleware.HttpLatencyClient{
-		Logger:    log.New(os.Stdout, "", log.LstdFlags),
-		Transport: t,
-	}
 	httpClient = http.Client{
 		CheckRedirect: redirectPolicyFunc,
-		Transport:     httpLatencyMiddleWare,
-	}
-	if c.report {
-		l := log.New(w, "", log.LstdFlags)
-		httpClient.Transport = middleware.HttpLatencyClient{Logger: l}
+		Transport:     t,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
-	defer cancel()
 
-	switch c.verb {
-	case http.MethodGet:
-		req, err = http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	var jar *cookieJar
+	if cookieJarFile != "" {
+		jar, err = loadCookieJar(cookieJarFile)
 		if err != nil {
 			return err
 		}
-	case http.MethodPost:
-		postBodyReader := strings.NewReader(c.postBody)
-		req, err = http.NewRequestWithContext(ctx, http.MethodPost, c.url, postBodyReader)
-		if err != nil {
-			return err
+		httpClient.Jar = jar
+	}
+
+	var logger *log.Logger
+	if c.report {
+		logger = log.New(w, "", log.LstdFlags)
+	}
+	var transport http.RoundTripper = t
+	var recorder *middleware.HttpHarRecorder
+	if harFile != "" {
+		// Below the retries, so that every attempt is recorded.
+		recorder = &middleware.HttpHarRecorder{Transport: transport, MaxBodySize: maxHarBodySize}
+		transport = recorder
+	}
+	if c.report && !c.bench.enabled {
+		transport = middleware.HttpLatencyClient{
+			Logger:    logger,
+			Transport: transport,
+			JSON:      c.reportFormat == "json",
 		}
-		c.headers = append(c.headers, "Content-Type=application/json")
 	}
+	if c.retries > 0 {
+		transport = middleware.HttpRetryClient{
+			Logger:    logger,
+			Transport: transport,
+			Retries:   c.retries,
+			Backoff:   c.retryBackoff,
+			Timeout:   c.timeout,
+			// Downloads are only timed out until the headers arrive.
+			HeaderTimeout: outputFile != "",
+			RetryOn:       retryOn,
+		}
+	}
+	httpClient.Transport = transport
 
-	addHeaders(c, req)
-	addBasicAuth(c, req)
+	if c.parallel > 1 {
+		// Keep a connection per range instead of reconnecting.
+		t.MaxIdleConnsPerHost = c.parallel
+	}
 
-	for i := 0; i < c.numRequests; i++ {
-		r, err := httpClient.Do(req)
-		if err != nil {
-			return err
+	if replay {
+		err = replayHar(w, &httpClient, c, fs.Arg(0))
+	} else if c.bench.enabled {
+		// Keep a connection per worker instead of reconnecting.
+		t.MaxIdleConnsPerHost = c.bench.load.concurrency
+		c.bench.load.total = c.numRequests
+		if c.bench.load.duration > 0 && !set["num-requests"] {
+			c.bench.load.total = 0
 		}
-		defer r.Body.Close()
+		err = benchHttp(w, &httpClient, c)
+	} else {
+		err = runRequests(w, &httpClient, c, outputFile)
+	}
 
-		responseBody, err = io.ReadAll(r.Body)
-		if err != nil {
-			return err
+	if jar != nil {
+		saveErr := jar.save()
+		if err == nil {
+			err = saveErr
+		}
+	}
+	if recorder != nil {
+		harErr := writeHar(harFile, recorder)
+		if err == nil {
+			err = harErr
 		}
+	}
+	return err
+}
 
+// runRequests sends c.numRequests requests and prints the responses, or
+// saves the first one to outputFile.
+func runRequests(w io.Writer, client *http.Client, c httpConfig, outputFile string) error {
+	for i := 0; i < c.numRequests; i++ {
 		if outputFile != "" {
-			f, err := os.Create(outputFile)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			_, err = f.Write(responseBody)
+			start := time.Now()
+			r, size, err := saveResponse(client, c, outputFile)
 			if err != nil {
 				return err
 			}
-
 			fmt.Fprintf(w, "Data saved to: %s\n", outputFile)
+			return writeOut(w, c, r, size, time.Since(start))
+		}
+
+		start := time.Now()
+		r, err := sendRequest(client, c)
+		if err != nil {
+			return err
+		}
+		responseBody, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
 			return err
 		}
+		latency := time.Since(start)
+		if c.observe != nil {
+			c.observe(r, responseBody)
+		}
 
-		fmt.Fprintln(w, string(responseBody))
+		err = writeResponse(w, c, r, responseBody)
+		if err != nil {
+			return err
+		}
+		err = writeOut(w, c, r, int64(len(responseBody)), latency)
+		if err != nil {
+			return err
+		}
+		if c.fail && r.StatusCode >= 400 {
+			return HTTPStatusError{StatusCode: r.StatusCode, Status: r.Status}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,456 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// curlSetting is a mync http flag set from a curl option.
+type curlSetting struct {
+	name  string
+	value string
+}
+
+// curlBodyFlags set the request body. A body from -from-curl is only used
+// if none of them is given on the command line.
+var curlBodyFlags = []string{"body", "body-file", "data-urlencode", "form", "file", "content-type"}
+
+// curlIgnored are curl options that make no difference to the request.
+var curlIgnored = []string{"-s", "--silent", "-S", "--show-error", "-v", "--verbose", "--compressed", "-#", "--progress-bar"}
+
+// curlValueOptions are the curl short options taking a value.
+const curlValueOptions = "XHduAFox"
+
+var curlLongOptions = map[string]string{
+	"--request":        "-X",
+	"--header":         "-H",
+	"--data":           "-d",
+	"--data-ascii":     "-d",
+	"--data-raw":       "--data-raw",
+	"--data-binary":    "--data-binary",
+	"--data-urlencode": "--data-urlencode",
+	"--form":           "-F",
+	"--user":           "-u",
+	"--user-agent":     "-A",
+	"--output":         "-o",
+	"--insecure":       "-k",
+	"--location":       "-L",
+	"--include":        "-i",
+	"--head":           "-I",
+	"--url":            "--url",
+	"--max-time":       "--max-time",
+	"--retry":          "--retry",
+	"--cacert":         "--cacert",
+	"--cert":           "--cert",
+	"--key":            "--key",
+	"--proxy":          "-x",
+	"--unix-socket":    "--unix-socket",
+	"--resolve":        "--resolve",
+}
+
+var curlLongValueOptions = []string{"--data-raw", "--data-binary", "--data-urlencode", "--url", "--max-time", "--retry", "--cacert", "--cert", "--key", "--unix-socket", "--resolve"}
+
+// splitShellWords splits a command line into words the way a POSIX shell
+// does, handling quotes and backslashes but no expansions.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\':
+			i++
+			if i == len(s) {
+				return nil, fmt.Errorf("%w: trailing backslash", ErrInvalidCurl)
+			}
+			if s[i] != '\n' {
+				word.WriteByte(s[i])
+				inWord = true
+			}
+		case ch == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidCurl)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case ch == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidCurl)
+			}
+			inWord = true
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// curlOptions splits the words of a curl command into options with their
+// values, a value of "" for options without one, and the URL.
+func curlOptions(words []string) ([][2]string, string, error) {
+	var options [][2]string
+	var url string
+	for i := 0; i < len(words); i++ {
+		arg := words[i]
+		needValue := func(opt string) (string, error) {
+			i++
+			if i == len(words) {
+				return "", fmt.Errorf("%w: %s needs a value", ErrInvalidCurl, opt)
+			}
+			return words[i], nil
+		}
+		switch {
+		case slices.Contains(curlIgnored, arg):
+		case strings.HasPrefix(arg, "--"):
+			opt, ok := curlLongOptions[arg]
+			if !ok {
+				return nil, "", fmt.Errorf("%w: unsupported option %s", ErrInvalidCurl, arg)
+			}
+			var value string
+			if slices.Contains(curlLongValueOptions, opt) || (len(opt) == 2 && strings.IndexByte(curlValueOptions, opt[1]) >= 0) {
+				var err error
+				value, err = needValue(arg)
+				if err != nil {
+					return nil, "", err
+				}
+			}
+			if opt == "--url" {
+				if len(url) != 0 {
+					return nil, "", fmt.Errorf("%w: only one URL is supported", ErrInvalidCurl)
+				}
+				url = value
+				continue
+			}
+			options = append(options, [2]string{opt, value})
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Short options can be combined, as in -sSL or -XPOST.
+			for j := 1; j < len(arg); j++ {
+				opt := "-" + arg[j:j+1]
+				if slices.Contains(curlIgnored, opt) {
+					continue
+				}
+				if strings.IndexByte(curlValueOptions, arg[j]) >= 0 {
+					value := arg[j+1:]
+					if len(value) == 0 {
+						var err error
+						value, err = needValue(opt)
+						if err != nil {
+							return nil, "", err
+						}
+					}
+					options = append(options, [2]string{opt, value})
+					break
+				}
+				if strings.IndexByte("kLiI", arg[j]) < 0 {
+					return nil, "", fmt.Errorf("%w: unsupported option %s", ErrInvalidCurl, opt)
+				}
+				options = append(options, [2]string{opt, ""})
+			}
+		default:
+			if len(url) != 0 {
+				return nil, "", fmt.Errorf("%w: only one URL is supported", ErrInvalidCurl)
+			}
+			url = arg
+		}
+	}
+	return options, url, nil
+}
+
+// parseCurl returns the mync http flags to set for a curl command, and its
+// URL. As curl, it sends data with POST unless -X is given and doesn't
+// follow redirects without -L.
+func parseCurl(command string) ([]curlSetting, string, error) {
+	words, err := splitShellWords(command)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(words) == 0 || words[0] != "curl" {
+		return nil, "", fmt.Errorf("%w: must start with curl", ErrInvalidCurl)
+	}
+	options, url, err := curlOptions(words[1:])
+	if err != nil {
+		return nil, "", err
+	}
+
+	var settings []curlSetting
+	var data, dataFiles []string
+	var verb, contentType string
+	var hasBody, location bool
+	for _, o := range options {
+		opt, value := o[0], o[1]
+		switch opt {
+		case "-X":
+			verb = value
+		case "-I":
+			verb = http.MethodHead
+		case "-H", "-A":
+			name, v, ok := strings.Cut(value, ":")
+			if opt == "-A" {
+				name, v, ok = "User-Agent", value, true
+			}
+			if !ok {
+				return nil, "", fmt.Errorf("%w: header %q must be name: value", ErrInvalidCurl, value)
+			}
+			name, v = strings.TrimSpace(name), strings.TrimSpace(v)
+			if strings.EqualFold(name, "Content-Type") {
+				contentType = v
+				continue
+			}
+			settings = append(settings, curlSetting{"header", name + "=" + v})
+		case "-d", "--data-raw", "--data-binary":
+			hasBody = true
+			switch {
+			case opt == "--data-binary" && strings.HasPrefix(value, "@"):
+				dataFiles = append(dataFiles, value[1:])
+				continue
+			case opt == "-d" && strings.HasPrefix(value, "@"):
+				// As curl, -d strips the newlines of a file, unlike
+				// --data-binary.
+				body, err := readBodyFile(value[1:])
+				if err != nil {
+					return nil, "", fmt.Errorf("%w: %w", ErrInvalidCurl, err)
+				}
+				value = strings.NewReplacer("\r", "", "\n", "").Replace(body)
+			}
+			data = append(data, value)
+		case "--data-urlencode":
+			hasBody = true
+			settings = append(settings, curlSetting{"data-urlencode", value})
+		case "-F":
+			hasBody = true
+			name, v, _ := strings.Cut(value, "=")
+			if strings.HasPrefix(v, "@") {
+				settings = append(settings, curlSetting{"file", value})
+			} else {
+				settings = append(settings, curlSetting{"form", name + "=" + v})
+			}
+		case "-u":
+			user, password, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, "", fmt.Errorf("%w: user %q must be user:password", ErrInvalidCurl, value)
+			}
+			settings = append(settings, curlSetting{"basicAuth", user + "=" + password})
+		case "-k":
+			settings = append(settings, curlSetting{"insecure-skip-verify", "true"})
+		case "-L":
+			location = true
+		case "-i":
+			settings = append(settings, curlSetting{"include", "true"})
+		case "-o":
+			settings = append(settings, curlSetting{"output", value})
+		case "--max-time":
+			settings = append(settings, curlSetting{"timeout", value + "s"})
+		case "--retry":
+			settings = append(settings, curlSetting{"retries", value})
+		case "--cacert":
+			settings = append(settings, curlSetting{"cacert", value})
+		case "--cert":
+			settings = append(settings, curlSetting{"cert", value})
+		case "--key":
+			settings = append(settings, curlSetting{"key", value})
+		case "-x":
+			settings = append(settings, curlSetting{"proxy", value})
+		case "--unix-socket":
+			settings = append(settings, curlSetting{"unix-socket", value})
+		case "--resolve":
+			settings = append(settings, curlSetting{"resolve", value})
+		}
+	}
+
+	if len(dataFiles) > 1 || (len(dataFiles) != 0 && len(data) != 0) {
+		return nil, "", fmt.Errorf("%w: a data file cannot be combined with other data", ErrInvalidCurl)
+	}
+	if len(dataFiles) != 0 {
+		settings = append(settings, curlSetting{"body-file", dataFiles[0]})
+	}
+	if len(data) != 0 {
+		settings = append(settings, curlSetting{"body", strings.Join(data, "&")})
+	}
+	if (len(data) != 0 || len(dataFiles) != 0) && len(contentType) == 0 {
+		// The curl default, mync sends JSON by default.
+		contentType = "application/x-www-form-urlencoded"
+	}
+	if len(contentType) != 0 {
+		if hasBody {
+			settings = append(settings, curlSetting{"content-type", contentType})
+		} else {
+			settings = append(settings, curlSetting{"header", "Content-Type=" + contentType})
+		}
+	}
+	if len(verb) == 0 && hasBody {
+		verb = http.MethodPost
+	}
+	if len(verb) != 0 {
+		settings = append(settings, curlSetting{"verb", strings.ToUpper(verb)})
+	}
+	if !location {
+		settings = append(settings, curlSetting{"disable-redirect", "true"})
+	}
+	return settings, url, nil
+}
+
+// applyCurl sets the flags of a curl command that aren't given on the
+// command line, the body only if no body flag is, and returns its URL.
+func applyCurl(fs *flag.FlagSet, set map[string]bool, headers []string, command string) (string, error) {
+	settings, url, err := parseCurl(command)
+	if err != nil {
+		return "", err
+	}
+	given := map[string]bool{}
+	for _, h := range headers {
+		name, _, _ := strings.Cut(h, "=")
+		given[strings.ToLower(name)] = true
+	}
+	bodyGiven := slices.ContainsFunc(curlBodyFlags, func(name string) bool { return set[name] })
+
+	applied := map[string]bool{}
+	for _, s := range settings {
+		switch {
+		case slices.Contains(curlBodyFlags, s.name) && bodyGiven:
+			continue
+		case s.name == "header":
+			name, _, _ := strings.Cut(s.value, "=")
+			if given[strings.ToLower(name)] {
+				continue
+			}
+		case set[s.name]:
+			continue
+		}
+		err := fs.Set(s.name, s.value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %w", ErrInvalidCurl, s.name, err)
+		}
+		applied[s.name] = true
+	}
+	for name := range applied {
+		set[name] = true
+	}
+	return url, nil
+}
+
+// shellQuote quotes s for a POSIX shell if it needs to be.
+func shellQuote(s string) string {
+	if len(s) != 0 && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%+=:,./_-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeCurl prints the request c describes as a curl command. A body read
+// from a file other than stdin is referred to by its path.
+func writeCurl(w io.Writer, c httpConfig, set map[string]bool, bodyFile, outputFile string) error {
+	headerConfig := c
+	headerConfig.parts = nil
+	headerConfig.basicAuth = ""
+	req, err := newRequest(context.Background(), headerConfig)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"curl"}
+	hasBody := len(c.body) != 0 || len(c.parts) != 0
+	switch {
+	case c.verb == http.MethodHead:
+		args = append(args, "-I")
+	case c.verb == http.MethodGet, c.verb == http.MethodPost && hasBody:
+	default:
+		args = append(args, "-X", c.verb)
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range req.Header[name] {
+			args = append(args, "-H", name+": "+v)
+		}
+	}
+	if len(c.basicAuth) != 0 {
+		user, password, _ := strings.Cut(c.basicAuth, "=")
+		args = append(args, "-u", user+":"+password)
+	}
+
+	switch {
+	case len(bodyFile) != 0 && bodyFile != "-":
+		args = append(args, "--data-binary", "@"+bodyFile)
+	case len(c.body) != 0:
+		args = append(args, "--data-binary", c.body)
+	}
+	for _, p := range c.parts {
+		if len(p.path) != 0 {
+			args = append(args, "-F", p.name+"=@"+p.path)
+		} else {
+			args = append(args, "-F", p.name+"="+p.value)
+		}
+	}
+
+	if !c.disableRedirect {
+		args = append(args, "-L")
+	}
+	if c.tls.insecureSkipVerify {
+		args = append(args, "-k")
+	}
+	for _, f := range []struct{ opt, value string }{
+		{"--cacert", c.tls.caCert},
+		{"--cert", c.tls.cert},
+		{"--key", c.tls.key},
+		{"-x", c.proxy},
+		{"--unix-socket", c.unixSocket},
+		{"-o", outputFile},
+	} {
+		if len(f.value) != 0 {
+			args = append(args, f.opt, f.value)
+		}
+	}
+	for _, r := range c.resolve {
+		args = append(args, "--resolve", r)
+	}
+	if set["timeout"] {
+		args = append(args, "--max-time", strconv.FormatFloat(c.timeout.Seconds(), 'f', -1, 64))
+	}
+	if c.retries > 0 {
+		args = append(args, "--retry", strconv.Itoa(c.retries))
+	}
+	if c.include {
+		args = append(args, "-i")
+	}
+	args = append(args, c.url)
+
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	_, err = fmt.Fprintln(w, strings.Join(args, " "))
+	return err
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// proxySchemes are the proxy URL schemes the transport supports. HTTP
+// proxies tunnel https requests with CONNECT and forward the others.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// parseProxy returns the proxy function of the transport, which uses the
+// proxy of the environment unless -proxy is given.
+func parseProxy(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if len(proxy) == 0 {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || len(u.Host) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, proxy)
+	}
+	for _, scheme := range proxySchemes {
+		if u.Scheme == scheme {
+			return http.ProxyURL(u), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, proxy)
+}
+
+// parseResolve parses -resolve host:port:addr entries, as curl's --resolve,
+// into the address to dial instead of each host:port.
+func parseResolve(entries []string) (map[string]string, error) {
+	resolve := map[string]string{}
+	for _, e := range entries {
+		parts := strings.SplitN(e, ":", 3)
+		if len(parts) != 3 || len(parts[0]) == 0 || len(parts[2]) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidResolve, e)
+		}
+		host, port, addr := parts[0], parts[1], parts[2]
+		_, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidResolve, e)
+		}
+		addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		resolve[net.JoinHostPort(host, port)] = net.JoinHostPort(addr, port)
+	}
+	return resolve, nil
+}
+
+// dialContext dials unixSocket for every connection if it is set, and the
+// addresses in resolve instead of the host and port they pin otherwise.
+func dialContext(dialer *net.Dialer, unixSocket string, resolve map[string]string) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if len(unixSocket) != 0 {
+			return dialer.DialContext(ctx, "unix", unixSocket)
+		}
+		if pinned, ok := resolve[addr]; ok {
+			addr = pinned
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
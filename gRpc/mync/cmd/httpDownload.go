@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partialSuffix is appended to the -output path while downloading. The
+// partial file is kept when a download fails so that it can be resumed.
+const partialSuffix = ".part"
+
+// validatorSuffix is appended to the partial file path for the file keeping
+// the ETag or Last-Modified date of the download, so that it is only
+// resumed if the file on the server did not change.
+const validatorSuffix = ".validator"
+
+// responseValidator returns the validator of r that can be sent in an
+// If-Range header: a strong ETag, or else the Last-Modified date.
+func responseValidator(r *http.Response) string {
+	if etag := r.Header.Get("ETag"); len(etag) != 0 && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return r.Header.Get("Last-Modified")
+}
+
+// saveValidator records the validator of the download into partial, or
+// removes a stale one if the server sent none.
+func saveValidator(partial, validator string) error {
+	path := partial + validatorSuffix
+	if len(validator) == 0 {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.WriteFile(path, []byte(validator), 0666)
+}
+
+// removePartial removes partial and its validator.
+func removePartial(partial string) error {
+	os.Remove(partial + validatorSuffix)
+	return os.Remove(partial)
+}
+
+// parseContentRange returns the first byte and the total size announced by
+// a Content-Range header such as "bytes 100-199/200" or "bytes */200". The
+// start is -1 for the latter and the total is -1 if the server does not
+// know it.
+func parseContentRange(r *http.Response) (int64, int64, bool) {
+	value, ok := strings.CutPrefix(r.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	span, total, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		size = -1
+	}
+	if span == "*" {
+		return -1, size, true
+	}
+	first, _, _ := strings.Cut(span, "-")
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
+
+func validateDownload(c httpConfig, outputFile string) error {
+	if c.parallel < 1 {
+		return ErrInvalidParallel
+	}
+	if c.parallel > 1 && (outputFile == "" || c.verb != http.MethodGet) {
+		return ErrInvalidParallel
+	}
+	if len(c.sha256) == 0 {
+		return nil
+	}
+	if outputFile == "" {
+		return ErrInvalidSha256
+	}
+	digest, err := hex.DecodeString(c.sha256)
+	if err != nil || len(digest) != sha256.Size {
+		return ErrInvalidSha256
+	}
+	return nil
+}
+
+// saveResponse downloads the response to outputFile, in -parallel ranges
+// if the server supports them. The body is written to a partial file
+// first, which is renamed once complete and, with -sha256, verified. It
+// returns the response and the size of the downloaded file.
+func saveResponse(client *http.Client, c httpConfig, outputFile string) (*http.Response, int64, error) {
+	partial := outputFile + partialSuffix
+	// A large file can take longer to download than any -timeout.
+	c.headerTimeout = true
+	var r *http.Response
+	var size int64
+	var err error
+	if c.parallel > 1 {
+		r, size, err = parallelDownload(client, c, partial)
+	} else {
+		r, size, err = download(client, c, partial)
+	}
+	if err != nil {
+		return r, 0, err
+	}
+
+	if len(c.sha256) != 0 {
+		err = verifySha256(partial, c.sha256)
+		if err != nil {
+			// Don't resume from data that is known to be bad.
+			removePartial(partial)
+			return r, 0, err
+		}
+	}
+	os.Remove(partial + validatorSuffix)
+	return r, size, os.Rename(partial, outputFile)
+}
+
+func verifySha256(path, expected string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(sum, expected) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, strings.ToLower(expected), sum)
+	}
+	return nil
+}
+
+// download streams the response to partial. If the file is left over from
+// an earlier attempt the download resumes where it stopped, provided the
+// server supports range requests and, if it sent a validator the first
+// time, the file did not change since.
+func download(client *http.Client, c httpConfig, partial string) (*http.Response, int64, error) {
+	var offset int64
+	var validator string
+	if info, err := os.Stat(partial); err == nil && info.Mode().IsRegular() {
+		offset = info.Size()
+		if data, err := os.ReadFile(partial + validatorSuffix); err == nil {
+			validator = string(data)
+		}
+	}
+
+	// Ask for the identity encoding, so that offsets into the partial file
+	// match the bytes the server counts in ranges.
+	c.extraHeaders = http.Header{"Accept-Encoding": {"identity"}}
+	if offset > 0 {
+		c.extraHeaders.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if len(validator) != 0 {
+			// The server sends the whole file instead if it changed.
+			c.extraHeaders.Set("If-Range", validator)
+		}
+	}
+	r, err := sendRequest(client, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Body.Close()
+
+	total := r.ContentLength
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case r.StatusCode == http.StatusPartialContent:
+		start, size, ok := parseContentRange(r)
+		if !ok || start != offset {
+			return r, 0, fmt.Errorf("%w: %q", ErrInvalidContentRange, r.Header.Get("Content-Range"))
+		}
+		if current := responseValidator(r); len(validator) != 0 && len(current) != 0 && current != validator {
+			// The server ignored If-Range, the file changed since.
+			r.Body.Close()
+			err = removePartial(partial)
+			if err != nil {
+				return r, 0, err
+			}
+			return download(client, c, partial)
+		}
+		flags = os.O_WRONLY | os.O_APPEND
+		total = size
+	case r.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// Either the partial file is already complete or the file on the
+		// server shrank, in which case it is downloaded again.
+		if _, size, ok := parseContentRange(r); ok && size == offset {
+			return r, offset, nil
+		}
+		err = removePartial(partial)
+		if err != nil {
+			return r, 0, err
+		}
+		return download(client, c, partial)
+	case r.StatusCode < 200 || r.StatusCode > 299:
+		return r, 0, HTTPStatusError{StatusCode: r.StatusCode, Status: r.Status}
+	default:
+		// The server ignored the range, or the file changed, and sent the
+		// whole file.
+		offset = 0
+		err = saveValidator(partial, responseValidator(r))
+		if err != nil {
+			return r, 0, err
+		}
+	}
+
+	f, err := os.OpenFile(partial, flags, 0666)
+	if err != nil {
+		return r, 0, err
+	}
+	p := newProgress(stderr, offset, total)
+	n, err := io.Copy(io.MultiWriter(f, p), r.Body)
+	p.done()
+	if err != nil {
+		f.Close()
+		return r, 0, err
+	}
+	return r, offset + n, f.Close()
+}
+
+// progress reports how much of a download was written, at most every
+// progressInterval so that fast downloads don't flood the terminal. It may
+// be written to by several parallel downloads.
+type progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	written int64
+	total   int64
+	last    time.Time
+}
+
+const progressInterval = 100 * time.Millisecond
+
+func newProgress(w io.Writer, written, total int64) *progress {
+	return &progress{w: w, written: written, total: total}
+}
+
+func (p *progress) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.written += int64(len(data))
+	if time.Since(p.last) >= progressInterval {
+		p.print()
+	}
+	return len(data), nil
+}
+
+func (p *progress) print() {
+	p.last = time.Now()
+	if p.total > 0 {
+		fmt.Fprintf(p.w, "\rDownloaded %s of %s (%d%%)", formatBytes(p.written), formatBytes(p.total), p.written*100/p.total)
+		return
+	}
+	fmt.Fprintf(p.w, "\rDownloaded %s", formatBytes(p.written))
+}
+
+func (p *progress) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.print()
+	fmt.Fprintln(p.w)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
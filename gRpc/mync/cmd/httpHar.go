@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"mync/middleware"
+)
+
+// maxHarBodySize bounds the request and response bodies recorded by -har,
+// so that a large download doesn't end up in memory.
+const maxHarBodySize = 10 << 20
+
+// replayIgnoredHeaders differ between any two responses and are not
+// compared by replay.
+var replayIgnoredHeaders = map[string]bool{"Date": true}
+
+func harCreator() middleware.HarCreator {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok && len(info.Main.Version) != 0 {
+		version = info.Main.Version
+	}
+	return middleware.HarCreator{Name: "mync", Version: version}
+}
+
+func writeHar(path string, recorder *middleware.HttpHarRecorder) error {
+	data, err := json.MarshalIndent(recorder.Har(harCreator()), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func readHar(path string) (middleware.Har, error) {
+	var har middleware.Har
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return har, err
+	}
+	err = json.Unmarshal(data, &har)
+	if err != nil {
+		return har, fmt.Errorf("%w: %w", ErrInvalidHar, err)
+	}
+	return har, nil
+}
+
+// replayHar sends the requests recorded in a HAR file again, one by one and
+// without following redirects as those were recorded as well, and prints
+// how the responses differ from the recorded ones.
+func replayHar(w io.Writer, client *http.Client, c httpConfig, path string) error {
+	har, err := readHar(path)
+	if err != nil {
+		return err
+	}
+	replayClient := *client
+	replayClient.Jar = nil
+	replayClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var differ int
+	for _, e := range har.Log.Entries {
+		diffs := replayEntry(&replayClient, c, e)
+		fmt.Fprintf(w, "%s %s: ", e.Request.Method, e.Request.URL)
+		if len(diffs) == 0 {
+			fmt.Fprintln(w, "unchanged")
+			continue
+		}
+		differ++
+		fmt.Fprintln(w, "differs")
+		for _, d := range diffs {
+			fmt.Fprintf(w, "  %s\n", d)
+		}
+	}
+	fmt.Fprintf(w, "Replayed %d requests, %d differ\n", len(har.Log.Entries), differ)
+	if differ != 0 {
+		return fmt.Errorf("%w: %d of %d", ErrReplayMismatch, differ, len(har.Log.Entries))
+	}
+	return nil
+}
+
+func replayRequest(ctx context.Context, e middleware.HarEntry) (*http.Request, error) {
+	var body []byte
+	if pd := e.Request.PostData; pd != nil {
+		var err error
+		body, err = middleware.HarDecode(pd.Text, pd.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidHar, err)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, e.Request.Method, e.Request.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range e.Request.Headers {
+		switch http.CanonicalHeaderKey(h.Name) {
+		case "Host":
+			req.Host = h.Value
+		case "Content-Length":
+			// Set from the body.
+		default:
+			req.Header.Add(h.Name, h.Value)
+		}
+	}
+	return req, nil
+}
+
+// replayEntry sends the request of e and describes how the response
+// differs from the recorded one.
+func replayEntry(client *http.Client, c httpConfig, e middleware.HarEntry) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	if c.retries > 0 {
+		cancel()
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+
+	req, err := replayRequest(ctx, e)
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	recorded := e.Response
+	r, err := client.Do(req)
+	if err != nil {
+		if recorded.Status == 0 {
+			// The request failed when it was recorded, too.
+			return nil
+		}
+		return []string{"error: " + tlsError(err).Error()}
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+
+	var diffs []string
+	if recorded.Status != r.StatusCode {
+		diffs = append(diffs, fmt.Sprintf("status: %s -> %s", recordedStatus(e), r.Status))
+	}
+	diffs = append(diffs, diffHeaders(recorded.Headers, r.Header)...)
+
+	recordedBody, err := middleware.HarDecode(recorded.Content.Text, recorded.Content.Encoding)
+	if err != nil {
+		return append(diffs, fmt.Sprintf("body: %v", err))
+	}
+	if len(recorded.Content.Comment) != 0 && len(body) > len(recordedBody) {
+		// Only the start of a truncated body was recorded.
+		body = body[:len(recordedBody)]
+	}
+	if !bytes.Equal(recordedBody, body) {
+		diffs = append(diffs, diffBodies(recordedBody, body)...)
+	}
+	return diffs
+}
+
+func recordedStatus(e middleware.HarEntry) string {
+	if e.Response.Status == 0 {
+		return fmt.Sprintf("error (%s)", e.Comment)
+	}
+	return fmt.Sprintf("%d %s", e.Response.Status, e.Response.StatusText)
+}
+
+func diffHeaders(recorded []middleware.HarNameValue, header http.Header) []string {
+	old := map[string][]string{}
+	for _, h := range recorded {
+		name := http.CanonicalHeaderKey(h.Name)
+		old[name] = append(old[name], h.Value)
+	}
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range header {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		if !replayIgnoredHeaders[name] {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+
+	var diffs []string
+	for _, name := range sorted {
+		before, after := strings.Join(old[name], ", "), strings.Join(header[name], ", ")
+		_, had := old[name]
+		_, has := header[name]
+		switch {
+		case !had:
+			diffs = append(diffs, fmt.Sprintf("header %s: added %q", name, after))
+		case !has:
+			diffs = append(diffs, fmt.Sprintf("header %s: removed %q", name, before))
+		case before != after:
+			diffs = append(diffs, fmt.Sprintf("header %s: %q -> %q", name, before, after))
+		}
+	}
+	return diffs
+}
+
+// diffBodies compares JSON bodies pretty printed, so that changed fields
+// show up on lines of their own.
+func diffBodies(recorded, body []byte) []string {
+	if !utf8.Valid(recorded) || !utf8.Valid(body) {
+		return []string{fmt.Sprintf("body: %d bytes -> %d bytes", len(recorded), len(body))}
+	}
+	diffs := []string{"body:"}
+	before := strings.Split(string(prettyJson(recorded)), "\n")
+	after := strings.Split(string(prettyJson(body)), "\n")
+	for _, line := range diffLines(before, after) {
+		diffs = append(diffs, "  "+line)
+	}
+	return diffs
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// multipartPart is a -form field or, if path is set, a -file part.
+type multipartPart struct {
+	name  string
+	value string
+	path  string
+}
+
+// parseMultipartParts checks the -form name=value and -file field=@path
+// arguments, making sure every file can be opened before the upload starts.
+func parseMultipartParts(form, files []string) ([]multipartPart, error) {
+	var parts []multipartPart
+	for _, f := range form {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFormField, f)
+		}
+		parts = append(parts, multipartPart{name: name, value: value})
+	}
+	for _, f := range files {
+		name, path, ok := strings.Cut(f, "=")
+		path = strings.TrimPrefix(path, "@")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFilePart, f)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("%w: %s is a directory", ErrInvalidFilePart, path)
+		}
+		parts = append(parts, multipartPart{name: name, path: path})
+	}
+	return parts, nil
+}
+
+// newMultipartBody returns a multipart/form-data body that is encoded while
+// it is being sent, so that files are streamed from disk instead of being
+// held in memory. Bodies built with the same boundary are identical, which
+// lets the request be sent again.
+func newMultipartBody(parts []multipartPart, boundary string) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeMultipart(pw, parts, boundary))
+	}()
+	return pr
+}
+
+func writeMultipart(w io.Writer, parts []multipartPart, boundary string) error {
+	mw := multipart.NewWriter(w)
+	err := mw.SetBoundary(boundary)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range parts {
+		if len(p.path) == 0 {
+			fw, err := mw.CreateFormField(p.name)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(fw, p.value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		fw, err := mw.CreateFormFile(p.name, filepath.Base(p.path))
+		if err != nil {
+			return err
+		}
+		err = copyFile(fw, p.path)
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// setMultipartBody makes req send parts as multipart/form-data.
+func setMultipartBody(req *http.Request, parts []multipartPart) {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	req.Body = newMultipartBody(parts, boundary)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return newMultipartBody(parts, boundary), nil
+	}
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// writeOutData is what -write-out templates are executed against.
+type writeOutData struct {
+	URL         string
+	Method      string
+	Proto       string
+	StatusCode  int
+	Status      string
+	Header      http.Header
+	ContentType string
+	Size        int64
+	Latency     time.Duration
+}
+
+func parseWriteOut(text string) (*template.Template, error) {
+	if len(text) == 0 {
+		return nil, nil
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("write-out").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidWriteOut, err)
+	}
+	return tmpl, nil
+}
+
+// prettyJson indents body if it is JSON and returns it unchanged otherwise.
+func prettyJson(body []byte) []byte {
+	var b bytes.Buffer
+	if json.Indent(&b, body, "", "  ") != nil {
+		return body
+	}
+	return b.Bytes()
+}
+
+// writeResponse prints the response body, or the values selected from it
+// with -query, preceded by the status line and headers with -include.
+func writeResponse(w io.Writer, c httpConfig, r *http.Response, body []byte) error {
+	if c.include {
+		fmt.Fprintf(w, "%s %s\n", r.Proto, r.Status)
+		keys := make([]string, 0, len(r.Header))
+		for k := range r.Header {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, v := range r.Header[k] {
+				fmt.Fprintf(w, "%s: %s\n", k, v)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	if c.query != nil {
+		return writeQuery(w, c.query, body)
+	}
+	if c.prettyPrint {
+		body = prettyJson(body)
+	}
+	fmt.Fprintln(w, string(body))
+	return nil
+}
+
+func writeOut(w io.Writer, c httpConfig, r *http.Response, size int64, latency time.Duration) error {
+	if c.writeOut == nil {
+		return nil
+	}
+	return c.writeOut.Execute(w, writeOutData{
+		URL:         r.Request.URL.String(),
+		Method:      r.Request.Method,
+		Proto:       r.Proto,
+		StatusCode:  r.StatusCode,
+		Status:      r.Status,
+		Header:      r.Header,
+		ContentType: r.Header.Get("Content-Type"),
+		Size:        size,
+		Latency:     latency,
+	})
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// probeRanges sends a HEAD request to find out the size of the download and
+// whether the server accepts byte ranges for it.
+func probeRanges(client *http.Client, c httpConfig) (int64, bool, error) {
+	c.verb = http.MethodHead
+	c.extraHeaders = http.Header{"Accept-Encoding": {"identity"}}
+	r, err := sendRequest(client, c)
+	if err != nil {
+		return 0, false, err
+	}
+	r.Body.Close()
+	ok := r.StatusCode == http.StatusOK && r.Header.Get("Accept-Ranges") == "bytes" && r.ContentLength > 0
+	return r.ContentLength, ok, nil
+}
+
+// byteRange is an inclusive range of bytes of a download.
+type byteRange struct {
+	start, end int64
+}
+
+// splitRanges splits size bytes into at most n ranges of about equal size.
+func splitRanges(size int64, n int) []byteRange {
+	if int64(n) > size {
+		n = int(size)
+	}
+	var ranges []byteRange
+	chunk := size / int64(n)
+	for i := 0; i < n; i++ {
+		r := byteRange{start: int64(i) * chunk, end: int64(i+1)*chunk - 1}
+		if i == n-1 {
+			r.end = size - 1
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// parallelDownload downloads the ranges of a file concurrently into
+// partial, falling back to a single stream if the server does not support
+// ranges. A partial file with gaps cannot be resumed, so it is removed if
+// any range fails.
+func parallelDownload(client *http.Client, c httpConfig, partial string) (*http.Response, int64, error) {
+	size, ok, err := probeRanges(client, c)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return download(client, c, partial)
+	}
+
+	f, err := os.Create(partial)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = f.Truncate(size)
+	if err != nil {
+		f.Close()
+		os.Remove(partial)
+		return nil, 0, err
+	}
+
+	p := newProgress(stderr, 0, size)
+	ranges := splitRanges(size, c.parallel)
+	responses := make([]*http.Response, len(ranges))
+	errs := make([]error, len(ranges))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i, br := range ranges {
+		wg.Add(1)
+		go func(i int, br byteRange) {
+			defer wg.Done()
+			responses[i], errs[i] = downloadRange(ctx, client, c, f, br, p)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, br)
+	}
+	wg.Wait()
+	p.done()
+
+	err = f.Close()
+	for _, rangeErr := range errs {
+		if rangeErr != nil && !errors.Is(rangeErr, context.Canceled) {
+			err = rangeErr
+			break
+		}
+	}
+	if err != nil {
+		os.Remove(partial)
+		return nil, 0, err
+	}
+	return responses[0], size, nil
+}
+
+// downloadRange writes the bytes of br into f at their offset.
+func downloadRange(ctx context.Context, client *http.Client, c httpConfig, f *os.File, br byteRange, p *progress) (*http.Response, error) {
+	c.extraHeaders = http.Header{
+		"Accept-Encoding": {"identity"},
+		"Range":           {fmt.Sprintf("bytes=%d-%d", br.start, br.end)},
+	}
+	r, err := sendRequestContext(ctx, client, c)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusPartialContent {
+		return r, HTTPStatusError{StatusCode: r.StatusCode, Status: r.Status}
+	}
+	start, _, ok := parseContentRange(r)
+	if !ok || start != br.start {
+		return r, fmt.Errorf("%w: %q", ErrInvalidContentRange, r.Header.Get("Content-Range"))
+	}
+
+	w := io.NewOffsetWriter(f, br.start)
+	n, err := io.Copy(io.MultiWriter(w, p), io.LimitReader(r.Body, br.end-br.start+1))
+	if err == nil && n != br.end-br.start+1 {
+		err = io.ErrUnexpectedEOF
+	}
+	return r, err
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseRetryOn parses the -retry-on list of status codes, status classes
+// such as 5xx and the word connection for failures without a response.
+func parseRetryOn(s string) (func(*http.Response, error) bool, error) {
+	codes := map[int]bool{}
+	classes := map[int]bool{}
+	var connection bool
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		switch {
+		case v == "connection":
+			connection = true
+		case len(v) == 3 && strings.HasSuffix(v, "xx") && v[0] >= '1' && v[0] <= '5':
+			classes[int(v[0]-'0')] = true
+		default:
+			code, err := strconv.Atoi(v)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidRetryOn, v)
+			}
+			codes[code] = true
+		}
+	}
+
+	return func(resp *http.Response, err error) bool {
+		if err != nil {
+			return connection
+		}
+		return codes[resp.StatusCode] || classes[resp.StatusCode/100]
+	}, nil
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -9,8 +11,13 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -39,6 +46,7 @@ func startTestHttpServer() *httptest.Server {
 				headers = append(headers, fmt.Sprintf("%s=%s", k, v[0]))
 			}
 		}
+		sort.Strings(headers)
 		fmt.Fprint(w, strings.Join(headers, " "))
 	})
 	mux.HandleFunc("/debug-basicauth", func(w http.ResponseWriter, r *http.Request) {
@@ -57,28 +65,97 @@ func TestHandleHttp(t *testing.T) {
 http: A HTTP client.
  
 http: <options> server
+http: replay <options> file.har
 
 Options: 
   -basicAuth string
     	Add basic auth (username:password) credentials to the outgoing request
+  -bench
+    	Load test the server and print a summary instead of the responses
+  -bench-format string
+    	Format of the bench mode summary (text or json) (default "text")
   -body string
-    	JSON data for HTTP POST request
+    	Request body, sent as JSON unless -content-type is set
   -body-file string
-    	File containing JSON data for HTTP POST request
+    	File containing the request body (- for stdin)
+  -cacert string
+    	CA certificate file (PEM) used to verify the server
+  -cert string
+    	Client certificate file (PEM) for mutual TLS
+  -concurrency int
+    	Number of concurrent requests in bench mode (default 1)
+  -content-type string
+    	Content type of the request body, JSON or form-urlencoded by default
+  -cookie-jar string
+    	File to load cookies from and save them to (Netscape cookies.txt format)
+  -data-urlencode value
+    	Add a field to a form-urlencoded request body (key=value)
   -disable-redirect
     	Do not follow redirection request
+  -duration duration
+    	Duration of the load test in bench mode, overrides num-requests unless it is also set
+  -fail
+    	Exit with an error if the server responds with a 4xx or 5xx status
+  -file value
+    	Add a file to a multipart/form-data request body (field=@path)
+  -form value
+    	Add a field to a multipart/form-data request body (name=value)
+  -from-curl string
+    	curl command to take the request from, overridden by flags
+  -har string
+    	File to record the requests and responses into (HAR 1.2 format)
   -header value
     	Add one or more headers to the outgoing request (key=value)
+  -include
+    	Print the response status line and headers before the body
+  -insecure-skip-verify
+    	Do not verify the server's TLS certificate
+  -key string
+    	Client private key file (PEM) for mutual TLS
   -max-idle-conns int
     	Maximum number of idle connections for the connection pool
   -num-requests int
     	Number of requests to make (default 1)
   -output string
     	File path to write the response into
+  -parallel int
+    	Number of byte ranges to download concurrently with -output (default 1)
+  -pretty-print
+    	Pretty print JSON response bodies
+  -print-curl
+    	Print the request as a curl command instead of sending it
+  -profile string
+    	Named profile in the config file to take defaults from, overridden by flags
+  -proxy string
+    	Proxy URL (http, https or socks5), instead of the proxy of the environment
+  -query string
+    	Print the values selected from the JSON response body, e.g. .repo[0].owner.id
+  -rate float
+    	Maximum number of requests per second in bench mode (0 for no limit)
   -report
     	report this http request's latency
+  -report-format string
+    	Format of the latency report (text or json) (default "text")
+  -resolve value
+    	Connect to addr instead of host:port, e.g. example.com:443:127.0.0.1 (host:port:addr)
+  -retries int
+    	Number of times to retry a failed request
+  -retry-backoff duration
+    	Delay before the first retry, doubled for every further retry (default 100ms)
+  -retry-on string
+    	Comma separated status codes (e.g. 503 or 5xx) and connection errors to retry on (default "5xx,429,connection")
+  -server-name string
+    	Name to verify the server's TLS certificate against
+  -sha256 string
+    	Expected SHA-256 digest (hex) of the file downloaded with -output
+  -timeout duration
+    	Timeout of each request, including reading the response unless it is saved with -output (default 200ms)
+  -unix-socket string
+    	Unix socket to connect to instead of the host of the URL
   -verb string
     	HTTP method (default "GET")
+  -write-out string
+    	Template printed after each response, e.g. '{{.StatusCode}} {{.Latency}}'
 `
 	ts := startTestHttpServer()
 	defer ts.Close()
@@ -118,7 +195,7 @@ Options:
 		},
 		{
 			name:   "test4",
-			args:   []string{"-verb", "PUT", "http://localhost"},
+			args:   []string{"-verb", "TRACE", "http://localhost"},
 			errMsg: ErrInvalidHTTPMethod.Error(),
 			output: "",
 		},
@@ -186,3 +263,731 @@ Options:
 		w.Reset()
 	}
 }
+
+func TestHandleHttpBench(t *testing.T) {
+	var mu sync.Mutex
+	var received []int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		received = append(received, len(data))
+		mu.Unlock()
+		if len(data) == 0 {
+			http.Error(w, "empty body", http.StatusBadRequest)
+		}
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	jsonBody := `{"id":1}`
+	tests := []struct {
+		name     string
+		args     []string
+		errMsg   string
+		calls    int
+		codes    map[string]int
+		errors   int
+		maxCalls int
+	}{
+		{
+			name:  "test1",
+			args:  []string{"-bench", "-num-requests", "10", "-concurrency", "3", "-verb", "POST", "-body", jsonBody, ts.URL + "/upload"},
+			calls: 10,
+			codes: map[string]int{"200": 10},
+		},
+		{
+			name:  "test2",
+			args:  []string{"-bench", "-num-requests", "4", ts.URL + "/flaky?fail=1"},
+			calls: 4,
+			codes: map[string]int{"503": 4},
+		},
+		{
+			name:   "test3",
+			args:   []string{"-bench", "-num-requests", "3", closed.URL},
+			calls:  3,
+			codes:  map[string]int{},
+			errors: 3,
+		},
+		{
+			name:     "test4",
+			args:     []string{"-bench", "-duration", "250ms", "-rate", "20", "-concurrency", "2", ts.URL + "/flaky"},
+			codes:    map[string]int{},
+			maxCalls: 6,
+		},
+		{
+			name:   "test5",
+			args:   []string{"-bench", "-concurrency", "0", ts.URL},
+			errMsg: ErrInvalidBenchConcurrency.Error(),
+		},
+		{
+			name:   "test6",
+			args:   []string{"-concurrency", "2", ts.URL},
+			errMsg: ErrInvalidBenchCommand.Error(),
+		},
+		{
+			name:   "test7",
+			args:   []string{"-bench", "-output", "file.out", ts.URL},
+			errMsg: ErrInvalidBenchOutput.Error(),
+		},
+		{
+			name:   "test8",
+			args:   []string{"-bench", "-bench-format", "xml", ts.URL},
+			errMsg: ErrInvalidBenchFormat.Error(),
+		},
+	}
+
+	w := new(bytes.Buffer)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			received = nil
+			err := HandleHttp(w, append([]string{"-bench-format", "json"}, tc.args...))
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if errMsg != tc.errMsg {
+				t.Fatalf("Expected error message `%s`, got `%s`", tc.errMsg, errMsg)
+			}
+			if len(tc.errMsg) != 0 {
+				return
+			}
+
+			var sum loadSummary
+			err = json.Unmarshal(w.Bytes(), &sum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.maxCalls != 0 {
+				if sum.Calls < 1 || sum.Calls > tc.maxCalls {
+					t.Errorf("Expected between 1 and %d calls, got: %d", tc.maxCalls, sum.Calls)
+				}
+				return
+			}
+			if sum.Calls != tc.calls || sum.Errors != tc.errors {
+				t.Errorf("Expected %d calls and %d errors, got: %d and %d", tc.calls, tc.errors, sum.Calls, sum.Errors)
+			}
+			if diff := cmp.Diff(sum.StatusCodes, tc.codes); diff != "" {
+				t.Error(diff)
+			}
+			for _, n := range received {
+				if n != len(jsonBody) {
+					t.Errorf("Expected every request to carry the body, got: %v", received)
+					break
+				}
+			}
+		})
+		w.Reset()
+	}
+}
+
+func TestHandleHttpRetry(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	bodies := map[string][]string{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		key := r.URL.Query().Get("key")
+		mu.Lock()
+		attempts[key]++
+		n := attempts[key]
+		bodies[key] = append(bodies[key], string(data))
+		mu.Unlock()
+
+		fail, _ := strconv.Atoi(r.URL.Query().Get("fail"))
+		if n <= fail {
+			if after := r.URL.Query().Get("after"); after != "" {
+				w.Header().Set("Retry-After", after)
+			}
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		errMsg   string
+		output   string
+		attempts int
+		minTime  time.Duration
+		logLines []string
+	}{
+		{
+			name:     "test1",
+			args:     []string{"-retries", "3", "-retry-backoff", "1ms", ts.URL + "/flaky?key=test1&fail=2"},
+			output:   "ok\n",
+			attempts: 3,
+		},
+		{
+			name:     "test2",
+			args:     []string{"-retries", "2", "-retry-backoff", "1ms", "-verb", "POST", "-body", `{"id":1}`, ts.URL + "/flaky?key=test2&fail=1"},
+			output:   "ok\n",
+			attempts: 2,
+		},
+		{
+			name:     "test3",
+			args:     []string{"-retries", "1", "-retry-backoff", "1ms", ts.URL + "/flaky?key=test3&fail=3"},
+			output:   "unavailable\n\n",
+			attempts: 2,
+		},
+		{
+			name:     "test4",
+			args:     []string{"-retries", "3", "-retry-on", "502,504", ts.URL + "/flaky?key=test4&fail=1"},
+			output:   "unavailable\n\n",
+			attempts: 1,
+		},
+		{
+			name:     "test5",
+			args:     []string{"-retries", "1", "-retry-backoff", "1h", ts.URL + "/flaky?key=test5&fail=1&after=1"},
+			output:   "ok\n",
+			attempts: 2,
+			minTime:  time.Second,
+		},
+		{
+			name:     "test6",
+			args:     []string{"-retries", "2", "-retry-backoff", "1ms", "-report", closed.URL},
+			errMsg:   "connection refused",
+			logLines: []string{"attempt=1 status=none", "attempt=2 status=none"},
+		},
+		{
+			name:     "test7",
+			args:     []string{"-retries", "1", "-retry-backoff", "1ms", "-report", ts.URL + "/flaky?key=test7&fail=1"},
+			attempts: 2,
+			logLines: []string{"attempt=1 status=503", "method=GET protocol=HTTP/1.1"},
+		},
+		{
+			name:   "test8",
+			args:   []string{"-retries", "1", "-retry-on", "5xx,timeout", ts.URL},
+			errMsg: ErrInvalidRetryOn.Error() + `: "timeout"`,
+		},
+		{
+			name:   "test9",
+			args:   []string{"-retries", "-1", ts.URL},
+			errMsg: ErrInvalidRetries.Error(),
+		},
+		{
+			name:     "test10",
+			args:     []string{"-retries", "1", "-retry-backoff", "1ms", ts.URL + "/flaky?key=test10&fail=1&after=999999999999"},
+			output:   "unavailable\n\n",
+			attempts: 1,
+		},
+		{
+			name:     "test11",
+			args:     []string{"-retries", "1", "-retry-backoff", "1ms", ts.URL + "/flaky?key=test11&fail=1&after=Fri,%2031%20Dec%209999%2023:59:59%20GMT"},
+			output:   "unavailable\n\n",
+			attempts: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			start := time.Now()
+			err := HandleHttp(w, tc.args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error message `%s`, got `%s`", tc.errMsg, errMsg)
+			}
+			if len(tc.output) != 0 && w.String() != tc.output {
+				t.Errorf("Expected output %q, got %q", tc.output, w.String())
+			}
+			for _, line := range tc.logLines {
+				if !strings.Contains(w.String(), line) {
+					t.Errorf("Expected log to contain %q, got:\n%s", line, w.String())
+				}
+			}
+			if time.Since(start) < tc.minTime {
+				t.Errorf("Expected the request to take at least %s", tc.minTime)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tc.attempts != 0 && attempts[tc.name] != tc.attempts {
+				t.Errorf("Expected %d attempts, got %d", tc.attempts, attempts[tc.name])
+			}
+			for _, body := range bodies[tc.name] {
+				if tc.name == "test2" && body != `{"id":1}` {
+					t.Errorf("Expected every attempt to carry the body, got: %q", bodies[tc.name])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHttpReport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer ts.Close()
+
+	t.Run("test1", func(t *testing.T) {
+		w := new(bytes.Buffer)
+		err := HandleHttp(w, []string{"-report", "-insecure-skip-verify", "-num-requests", "2", ts.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range []string{
+			"  TCP connect:",
+			"  TLS handshake:",
+			"  Time to first byte:",
+			"  Content transfer:",
+			"  Connection reused:  false\n",
+			"  Connection reused:  true\n",
+			"hello\n",
+		} {
+			if !strings.Contains(w.String(), line) {
+				t.Errorf("Expected report to contain %q, got:\n%s", line, w.String())
+			}
+		}
+	})
+
+	t.Run("test2", func(t *testing.T) {
+		w := new(bytes.Buffer)
+		err := HandleHttp(w, []string{"-report", "-report-format", "json", "-insecure-skip-verify", "-num-requests", "2", ts.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var timings []map[string]any
+		for _, line := range strings.Split(w.String(), "\n") {
+			if !strings.HasPrefix(line, "{") {
+				continue
+			}
+			var timing map[string]any
+			err := json.Unmarshal([]byte(line), &timing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			timings = append(timings, timing)
+		}
+		if len(timings) != 2 {
+			t.Fatalf("Expected 2 timings, got:\n%s", w.String())
+		}
+		if timings[0]["connReused"] != false || timings[1]["connReused"] != true {
+			t.Errorf("Expected only the second connection to be reused, got: %v", timings)
+		}
+		if timings[0]["tlsMs"].(float64) <= 0 || timings[0]["status"].(float64) != 200 {
+			t.Errorf("Expected a TLS handshake and status 200, got: %v", timings[0])
+		}
+		for _, key := range []string{"dnsMs", "connectMs", "ttfbMs", "transferMs", "totalMs"} {
+			if _, ok := timings[1][key]; !ok {
+				t.Errorf("Expected timing to contain %s, got: %v", key, timings[1])
+			}
+		}
+	})
+
+	t.Run("test3", func(t *testing.T) {
+		w := new(bytes.Buffer)
+		err := HandleHttp(w, []string{"-report", "-report-format", "xml", ts.URL})
+		if err == nil || err.Error() != ErrInvalidReportFormat.Error() {
+			t.Errorf("Expected error %v, got: %v", ErrInvalidReportFormat, err)
+		}
+	})
+}
+
+func TestHandleHttpVerbs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "%s content-type=%q body=%q", r.Method, r.Header.Get("Content-Type"), data)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	echo := ts.URL + "/echo"
+
+	bodyFile := filepath.Join(t.TempDir(), "body.txt")
+	err := os.WriteFile(bodyFile, []byte("file contents"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		output string
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			args:   []string{"-verb", "PUT", "-body", `{"id":1}`, echo},
+			output: `PUT content-type="application/json" body="{\"id\":1}"` + "\n",
+		},
+		{
+			name:   "test2",
+			args:   []string{"-verb", "PATCH", "-body", "name=mync", "-content-type", "text/plain", echo},
+			output: `PATCH content-type="text/plain" body="name=mync"` + "\n",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-verb", "DELETE", echo},
+			output: `DELETE content-type="" body=""` + "\n",
+		},
+		{
+			name:   "test4",
+			args:   []string{"-verb", "DELETE", "-body", `{"force":true}`, echo},
+			output: `DELETE content-type="application/json" body="{\"force\":true}"` + "\n",
+		},
+		{
+			name:   "test5",
+			args:   []string{"-verb", "OPTIONS", echo},
+			output: `OPTIONS content-type="" body=""` + "\n",
+		},
+		{
+			name:   "test6",
+			args:   []string{"-verb", "HEAD", echo},
+			output: "\n",
+		},
+		{
+			name:   "test7",
+			args:   []string{"-verb", "POST", "-data-urlencode", "a=1", "-data-urlencode", "b=x y&z", echo},
+			output: `POST content-type="application/x-www-form-urlencoded" body="a=1&b=x+y%26z"` + "\n",
+		},
+		{
+			name:   "test8",
+			args:   []string{"-verb", "PUT", "-body-file", "-", "-content-type", "application/octet-stream", echo},
+			stdin:  "from stdin",
+			output: `PUT content-type="application/octet-stream" body="from stdin"` + "\n",
+		},
+		{
+			name:   "test9",
+			args:   []string{"-verb", "PATCH", "-body-file", bodyFile, "-content-type", "text/plain", echo},
+			output: `PATCH content-type="text/plain" body="file contents"` + "\n",
+		},
+		{
+			name:   "test10",
+			args:   []string{"-body", `{"id":1}`, echo},
+			errMsg: ErrInvalidHTTPCommand.Error(),
+		},
+		{
+			name:   "test11",
+			args:   []string{"-verb", "OPTIONS", "-data-urlencode", "a=1", echo},
+			errMsg: ErrInvalidHTTPCommand.Error(),
+		},
+		{
+			name:   "test12",
+			args:   []string{"-verb", "HEAD", "-body-file", bodyFile, echo},
+			errMsg: ErrInvalidHTTPCommand.Error(),
+		},
+		{
+			name:   "test13",
+			args:   []string{"-verb", "PUT", "-content-type", "text/plain", echo},
+			errMsg: ErrInvalidContentType.Error(),
+		},
+		{
+			name:   "test14",
+			args:   []string{"-verb", "POST", "-body", "a=1", "-data-urlencode", "a=1", echo},
+			errMsg: ErrInvalidHTTPPostCommand.Error(),
+		},
+		{
+			name:   "test15",
+			args:   []string{"-verb", "POST", "-data-urlencode", "novalue", echo},
+			errMsg: ErrInvalidFormField.Error() + `: "novalue"`,
+		},
+		{
+			name:   "test16",
+			args:   []string{"-timeout", "0s", echo},
+			errMsg: ErrInvalidTimeout.Error(),
+		},
+		{
+			name:   "test17",
+			args:   []string{"-timeout", "50ms", ts.URL + "/slow"},
+			errMsg: "context deadline exceeded",
+		},
+		{
+			name:   "test18",
+			args:   []string{"-timeout", "2s", ts.URL + "/slow"},
+			output: "\n",
+		},
+	}
+
+	origStdin := stdin
+	defer func() { stdin = origStdin }()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			stdin = strings.NewReader(tc.stdin)
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, tc.args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error message `%s`, got `%s`", tc.errMsg, errMsg)
+			}
+			if diff := cmp.Diff(tc.output, w.String()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestHandleHttpMultipart(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		mu.Lock()
+		attempts[key]++
+		n := attempts[key]
+		mu.Unlock()
+
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		parts := []string{r.Method, fmt.Sprintf("chunked=%t", slices.Contains(r.TransferEncoding, "chunked"))}
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, err := io.ReadAll(p)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if p.FileName() != "" {
+				parts = append(parts, fmt.Sprintf("%s=@%s(%d bytes)", p.FormName(), p.FileName(), len(data)))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s=%s", p.FormName(), data))
+			}
+		}
+		if r.URL.Query().Get("fail") != "" && n == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, strings.Join(parts, " "))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	pkgFile := filepath.Join(dir, "mypackage-0.1.tar.gz")
+	err := os.WriteFile(pkgFile, bytes.Repeat([]byte("x"), 1<<20), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readme := filepath.Join(dir, "README")
+	err = os.WriteFile(readme, []byte("hello"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			args:   []string{"-verb", "POST", "-form", "name=mypackage", "-form", "version=0.1", ts.URL},
+			output: "POST chunked=true name=mypackage version=0.1\n",
+		},
+		{
+			name:   "test2",
+			args:   []string{"-verb", "POST", "-timeout", "5s", "-form", "name=mypackage", "-file", "filedata=@" + pkgFile, ts.URL},
+			output: "POST chunked=true name=mypackage filedata=@mypackage-0.1.tar.gz(1048576 bytes)\n",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-verb", "PUT", "-file", "readme=@" + readme, "-file", "pkg=" + pkgFile, "-timeout", "5s", ts.URL},
+			output: "PUT chunked=true readme=@README(5 bytes) pkg=@mypackage-0.1.tar.gz(1048576 bytes)\n",
+		},
+		{
+			name:   "test4",
+			args:   []string{"-verb", "POST", "-retries", "1", "-retry-backoff", "1ms", "-form", "name=retried", "-file", "readme=@" + readme, ts.URL + "?key=test4&fail=1"},
+			output: "POST chunked=true name=retried readme=@README(5 bytes)\n",
+		},
+		{
+			name:   "test5",
+			args:   []string{"-form", "name=mypackage", ts.URL},
+			errMsg: ErrInvalidHTTPCommand.Error(),
+		},
+		{
+			name:   "test6",
+			args:   []string{"-verb", "POST", "-file", "filedata=@" + filepath.Join(dir, "missing"), ts.URL},
+			errMsg: "no such file or directory",
+		},
+		{
+			name:   "test7",
+			args:   []string{"-verb", "POST", "-file", "filedata", ts.URL},
+			errMsg: ErrInvalidFilePart.Error() + `: "filedata"`,
+		},
+		{
+			name:   "test8",
+			args:   []string{"-verb", "POST", "-body", "{}", "-form", "name=mypackage", ts.URL},
+			errMsg: ErrInvalidHTTPPostCommand.Error(),
+		},
+		{
+			name:   "test9",
+			args:   []string{"-verb", "POST", "-content-type", "text/plain", "-form", "name=mypackage", ts.URL},
+			errMsg: ErrInvalidMultipartContentType.Error(),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, tc.args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error message `%s`, got `%s`", tc.errMsg, errMsg)
+			}
+			if diff := cmp.Diff(tc.output, w.String()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+	if attempts["test4"] != 2 {
+		t.Errorf("Expected the multipart body to be sent twice, got %d attempts", attempts["test4"])
+	}
+}
+
+func TestHandleHttpOutput(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Mync", "test")
+		fmt.Fprint(w, `{"id":1,"tags":["a","b"]}`)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Date"] = nil
+		http.Error(w, "not here", http.StatusNotFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		errMsg string
+	}{
+		{
+			name: "test1",
+			args: []string{"-include", ts.URL + "/json"},
+			output: "HTTP/1.1 200 OK\n" +
+				"Content-Length: 25\n" +
+				"Content-Type: application/json\n" +
+				"X-Mync: test\n" +
+				"\n" +
+				`{"id":1,"tags":["a","b"]}` + "\n",
+		},
+		{
+			name:   "test2",
+			args:   []string{"-pretty-print", ts.URL + "/json"},
+			output: "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}\n",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-pretty-print", ts.URL + "/missing"},
+			output: "not here\n\n",
+		},
+		{
+			name:   "test4",
+			args:   []string{"-fail", ts.URL + "/missing"},
+			output: "not here\n\n",
+			errMsg: "server responded with 404 Not Found",
+		},
+		{
+			name:   "test5",
+			args:   []string{"-fail", "-write-out", "{{.StatusCode}} {{.ContentType}} {{.Size}}", ts.URL + "/json"},
+			output: `{"id":1,"tags":["a","b"]}` + "\n200 application/json 25\n",
+		},
+		{
+			name:   "test6",
+			args:   []string{"-write-out", "{{.Method}} {{.Status}} {{index .Header \"X-Mync\"}}", "-num-requests", "2", ts.URL + "/json"},
+			output: strings.Repeat(`{"id":1,"tags":["a","b"]}`+"\nGET 200 OK [test]\n", 2),
+		},
+		{
+			name:   "test7",
+			args:   []string{"-write-out", "{{.StatusCode", ts.URL + "/json"},
+			errMsg: ErrInvalidWriteOut.Error(),
+		},
+		{
+			name:   "test8",
+			args:   []string{"-write-out", "{{.Missing}}", ts.URL + "/json"},
+			output: `{"id":1,"tags":["a","b"]}` + "\n",
+			errMsg: "can't evaluate field Missing",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, tc.args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error message `%s`, got `%s`", tc.errMsg, errMsg)
+			}
+			if diff := cmp.Diff(tc.output, w.String()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+
+	t.Run("test9", func(t *testing.T) {
+		w := new(bytes.Buffer)
+		err := HandleHttp(w, []string{"-write-out", "{{.Latency}}", ts.URL + "/json"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(w.String()), "\n")
+		_, err = time.ParseDuration(lines[len(lines)-1])
+		if err != nil {
+			t.Errorf("Expected a latency, got: %q", lines[len(lines)-1])
+		}
+	})
+
+	var statusErr HTTPStatusError
+	err := HandleHttp(new(bytes.Buffer), []string{"-fail", ts.URL + "/missing"})
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a HTTPStatusError with status 404, got: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// querySegment selects a field of an object, an element of an array or,
+// with all set, every element of an array.
+type querySegment struct {
+	field string
+	index int
+	isIdx bool
+	all   bool
+}
+
+func (s querySegment) String() string {
+	switch {
+	case s.all:
+		return "[*]"
+	case s.isIdx:
+		return fmt.Sprintf("[%d]", s.index)
+	default:
+		return "." + s.field
+	}
+}
+
+// jsonQuery is a parsed -query expression such as .repo[0].owner.id. Fields
+// are separated by dots, [n] picks an array element counting from the end
+// if negative and [*] or [] picks every element.
+type jsonQuery struct {
+	expr     string
+	segments []querySegment
+}
+
+func parseQuery(expr string) (*jsonQuery, error) {
+	if len(expr) == 0 {
+		return nil, nil
+	}
+	q := &jsonQuery{expr: expr}
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidQuery, expr, reason)
+	}
+	if expr[0] != '.' && expr[0] != '[' {
+		return nil, invalid("must start with . or [")
+	}
+
+	rest := expr
+	if rest == "." {
+		return q, nil
+	}
+	for len(rest) != 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, invalid("empty field name")
+			}
+			q.segments = append(q.segments, querySegment{field: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, invalid("missing ]")
+			}
+			idx := rest[1:end]
+			rest = rest[end+1:]
+			if idx == "" || idx == "*" {
+				q.segments = append(q.segments, querySegment{all: true})
+				continue
+			}
+			n, err := strconv.Atoi(idx)
+			if err != nil {
+				return nil, invalid(fmt.Sprintf("invalid index %q", idx))
+			}
+			q.segments = append(q.segments, querySegment{index: n, isIdx: true})
+		default:
+			return nil, invalid(fmt.Sprintf("unexpected %q", rest[0]))
+		}
+	}
+	return q, nil
+}
+
+// apply returns the values the query selects in the JSON document data.
+func (q *jsonQuery) apply(data []byte) ([]any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var doc any
+	err := d.Decode(&doc)
+	if err == nil && d.Decode(new(any)) != io.EOF {
+		err = fmt.Errorf("unexpected data after the JSON document")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrQueryNotJson, err)
+	}
+
+	values := []any{doc}
+	var path strings.Builder
+	for _, s := range q.segments {
+		var next []any
+		for _, v := range values {
+			selected, err := s.selectFrom(v)
+			if err != nil {
+				return nil, fmt.Errorf("%w %q: %s%s %s", ErrQueryNoMatch, q.expr, path.String(), s, err)
+			}
+			next = append(next, selected...)
+		}
+		path.WriteString(s.String())
+		values = next
+	}
+	return values, nil
+}
+
+func (s querySegment) selectFrom(v any) ([]any, error) {
+	if !s.isIdx && !s.all {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("is applied to %s, not an object", jsonKind(v))
+		}
+		field, ok := obj[s.field]
+		if !ok {
+			return nil, fmt.Errorf("does not exist")
+		}
+		return []any{field}, nil
+	}
+
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("is applied to %s, not an array", jsonKind(v))
+	}
+	if s.all {
+		return arr, nil
+	}
+	i := s.index
+	if i < 0 {
+		i += len(arr)
+	}
+	if i < 0 || i >= len(arr) {
+		return nil, fmt.Errorf("is out of range for %d elements", len(arr))
+	}
+	return []any{arr[i]}, nil
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	default:
+		return "null"
+	}
+}
+
+// writeQuery prints the values selected by q one per line, strings without
+// quotes and everything else as compact JSON.
+func writeQuery(w io.Writer, q *jsonQuery, data []byte) error {
+	values, err := q.apply(data)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			fmt.Fprintln(w, s)
+			continue
+		}
+		out, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(out))
+	}
+	return nil
+}
@@ -0,0 +1,381 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// benchOptions are the bench mode flags shared by the http and grpc
+// sub-commands.
+type benchOptions struct {
+	enabled bool
+	format  string
+	load    loadOptions
+}
+
+// addBenchFlags registers the bench mode flags, unit names what is being
+// sent in the help text, e.g. requests or calls.
+func addBenchFlags(fs *flag.FlagSet, b *benchOptions, unit string) {
+	fs.BoolVar(&b.enabled, "bench", false, "Load test the server and print a summary instead of the responses")
+	fs.IntVar(&b.load.concurrency, "concurrency", 1, fmt.Sprintf("Number of concurrent %s in bench mode", unit))
+	fs.DurationVar(&b.load.duration, "duration", 0, fmt.Sprintf("Duration of the load test in bench mode, overrides num-%s unless it is also set", unit))
+	fs.Float64Var(&b.load.rate, "rate", 0, fmt.Sprintf("Maximum number of %s per second in bench mode (0 for no limit)", unit))
+	fs.StringVar(&b.format, "bench-format", "text", "Format of the bench mode summary (text or json)")
+}
+
+// validateBenchOptions checks the bench flags, set holds the names of the
+// flags given on the command line.
+func validateBenchOptions(b benchOptions, set map[string]bool) error {
+	if !b.enabled {
+		for _, name := range []string{"concurrency", "duration", "rate", "bench-format", "connections", "num-calls"} {
+			if set[name] {
+				return ErrInvalidBenchCommand
+			}
+		}
+		return nil
+	}
+	if b.load.concurrency < 1 {
+		return ErrInvalidBenchConcurrency
+	}
+	if b.load.duration < 0 || b.load.rate < 0 || math.IsNaN(b.load.rate) {
+		return ErrInvalidBenchLimit
+	}
+	if b.format != "text" && b.format != "json" {
+		return ErrInvalidBenchFormat
+	}
+	return nil
+}
+
+// loadOptions control how runLoad schedules calls. A zero total or
+// duration means no limit, a zero rate means as fast as possible.
+type loadOptions struct {
+	concurrency int
+	total       int
+	duration    time.Duration
+	rate        float64
+}
+
+// runLoad calls fn from opts.concurrency workers until opts.total calls
+// were started or opts.duration elapsed, at most opts.rate calls per second.
+// Calls in flight when the duration elapses are allowed to finish.
+func runLoad(opts loadOptions, fn func(worker int)) {
+	ctx := context.Background()
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	// Rates too high for the ticker to pace are not limited.
+	var tokens <-chan time.Time
+	if interval := time.Duration(float64(time.Second) / opts.rate); opts.rate > 0 && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	var started atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-ctx.Done():
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				if opts.total > 0 && started.Add(1) > int64(opts.total) {
+					return
+				}
+				fn(worker)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// loadStats records the outcome of every call made during a load test.
+type loadStats struct {
+	mu        sync.Mutex
+	start     time.Time
+	elapsed   time.Duration
+	latencies []time.Duration
+	codes     map[string]int
+	errors    map[string]int
+
+	// Streaming calls also record the messages they received.
+	streams      int
+	messages     int
+	firstMessage []time.Duration
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{
+		start:  time.Now(),
+		codes:  map[string]int{},
+		errors: map[string]int{},
+	}
+}
+
+// record adds a call, code is the HTTP or gRPC status of the response and
+// err is set when the call failed before a response was received.
+func (s *loadStats) record(latency time.Duration, code string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies = append(s.latencies, latency)
+	if len(code) != 0 {
+		s.codes[code]++
+	}
+	if err != nil {
+		s.errors[err.Error()]++
+	}
+}
+
+// recordStream adds the messages received by a streaming call and how long
+// it took for the first one to arrive.
+func (s *loadStats) recordStream(messages int, firstMessage time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams++
+	s.messages += messages
+	if messages > 0 {
+		s.firstMessage = append(s.firstMessage, firstMessage)
+	}
+}
+
+func (s *loadStats) stop() {
+	s.elapsed = time.Since(s.start)
+}
+
+type latencySummary struct {
+	Min  float64 `json:"minMs"`
+	Mean float64 `json:"meanMs"`
+	P50  float64 `json:"p50Ms"`
+	P90  float64 `json:"p90Ms"`
+	P99  float64 `json:"p99Ms"`
+	Max  float64 `json:"maxMs"`
+}
+
+type histogramBucket struct {
+	UpperBound float64 `json:"upperBoundMs"`
+	Count      int     `json:"count"`
+}
+
+type streamSummary struct {
+	Messages     int            `json:"messages"`
+	Throughput   float64        `json:"messagesPerSecond"`
+	FirstMessage latencySummary `json:"timeToFirstMessage"`
+}
+
+type loadSummary struct {
+	Calls       int               `json:"calls"`
+	Errors      int               `json:"errors"`
+	Duration    float64           `json:"durationSeconds"`
+	Throughput  float64           `json:"callsPerSecond"`
+	Latency     latencySummary    `json:"latency"`
+	Histogram   []histogramBucket `json:"histogram"`
+	StatusCodes map[string]int    `json:"statusCodes"`
+	ErrorCounts map[string]int    `json:"errorMessages,omitempty"`
+	Streaming   *streamSummary    `json:"streaming,omitempty"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func summarizeLatencies(latencies []time.Duration) latencySummary {
+	if len(latencies) == 0 {
+		return latencySummary{}
+	}
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	return latencySummary{
+		Min:  milliseconds(sorted[0]),
+		Mean: milliseconds(total / time.Duration(len(sorted))),
+		P50:  milliseconds(percentile(sorted, 50)),
+		P90:  milliseconds(percentile(sorted, 90)),
+		P99:  milliseconds(percentile(sorted, 99)),
+		Max:  milliseconds(sorted[len(sorted)-1]),
+	}
+}
+
+// histogramBuckets is the number of equally wide latency buckets between
+// the fastest and the slowest call.
+const histogramBuckets = 10
+
+func histogram(latencies []time.Duration, l latencySummary) []histogramBucket {
+	if len(latencies) == 0 {
+		return nil
+	}
+	width := (l.Max - l.Min) / histogramBuckets
+	buckets := make([]histogramBucket, histogramBuckets)
+	for i := range buckets {
+		buckets[i].UpperBound = l.Min + width*float64(i+1)
+	}
+	for _, latency := range latencies {
+		i := histogramBuckets - 1
+		if width > 0 {
+			i = int((milliseconds(latency) - l.Min) / width)
+		}
+		if i >= histogramBuckets {
+			i = histogramBuckets - 1
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+func (s *loadStats) summary() loadSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := loadSummary{
+		Calls:       len(s.latencies),
+		Duration:    s.elapsed.Seconds(),
+		StatusCodes: s.codes,
+		ErrorCounts: s.errors,
+	}
+	for _, n := range s.errors {
+		sum.Errors += n
+	}
+	if s.elapsed > 0 {
+		sum.Throughput = float64(sum.Calls) / s.elapsed.Seconds()
+	}
+
+	sum.Latency = summarizeLatencies(s.latencies)
+	sum.Histogram = histogram(s.latencies, sum.Latency)
+
+	if s.streams > 0 {
+		sum.Streaming = &streamSummary{
+			Messages:     s.messages,
+			FirstMessage: summarizeLatencies(s.firstMessage),
+		}
+		if s.elapsed > 0 {
+			sum.Streaming.Throughput = float64(s.messages) / s.elapsed.Seconds()
+		}
+	}
+	return sum
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeLoadSummary(w io.Writer, sum loadSummary, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(sum, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(data))
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	defer tw.Flush()
+	w = tw
+
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "  Calls:\t%d\n", sum.Calls)
+	fmt.Fprintf(w, "  Errors:\t%d\n", sum.Errors)
+	fmt.Fprintf(w, "  Duration:\t%.3fs\n", sum.Duration)
+	fmt.Fprintf(w, "  Calls/sec:\t%.2f\n", sum.Throughput)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Latency:")
+	writeLatencies(w, sum.Latency)
+	if len(sum.Histogram) != 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Histogram:")
+		writeHistogram(w, sum.Histogram)
+	}
+	if sum.Streaming != nil {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Streaming:")
+		fmt.Fprintf(w, "  Messages:\t%d\n", sum.Streaming.Messages)
+		fmt.Fprintf(w, "  Messages/sec:\t%.2f\n", sum.Streaming.Throughput)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Time to first message:")
+		writeLatencies(w, sum.Streaming.FirstMessage)
+	}
+	if len(sum.StatusCodes) != 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Status codes:")
+		for _, code := range sortedKeys(sum.StatusCodes) {
+			fmt.Fprintf(w, "  %s:\t%d\n", code, sum.StatusCodes[code])
+		}
+	}
+	if len(sum.ErrorCounts) != 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Errors:")
+		for _, msg := range sortedKeys(sum.ErrorCounts) {
+			fmt.Fprintf(w, "  %s:\t%d\n", msg, sum.ErrorCounts[msg])
+		}
+	}
+	return nil
+}
+
+func writeLatencies(w io.Writer, l latencySummary) {
+	fmt.Fprintf(w, "  min:\t%.3fms\n", l.Min)
+	fmt.Fprintf(w, "  mean:\t%.3fms\n", l.Mean)
+	fmt.Fprintf(w, "  p50:\t%.3fms\n", l.P50)
+	fmt.Fprintf(w, "  p90:\t%.3fms\n", l.P90)
+	fmt.Fprintf(w, "  p99:\t%.3fms\n", l.P99)
+	fmt.Fprintf(w, "  max:\t%.3fms\n", l.Max)
+}
+
+// histogramWidth is the length of the bar of the fullest bucket.
+const histogramWidth = 40
+
+func writeHistogram(w io.Writer, buckets []histogramBucket) {
+	var most int
+	for _, b := range buckets {
+		if b.Count > most {
+			most = b.Count
+		}
+	}
+	for _, b := range buckets {
+		bar := 0
+		if most > 0 {
+			bar = b.Count * histogramWidth / most
+		}
+		fmt.Fprintf(w, "  %.3fms\t[%d]\t%s\n", b.UpperBound, b.Count, strings.Repeat("#", bar))
+	}
+}
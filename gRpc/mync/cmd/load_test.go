@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var errTest = errors.New("test error")
+
+func TestLoadSummary(t *testing.T) {
+	stats := newLoadStats()
+	for i := 1; i <= 100; i++ {
+		stats.record(time.Duration(i)*time.Millisecond, "200", nil)
+	}
+	stats.record(500*time.Millisecond, "", errTest)
+	stats.elapsed = 2 * time.Second
+
+	sum := stats.summary()
+	expected := latencySummary{Min: 1, Mean: 104.950495, P50: 51, P90: 91, P99: 100, Max: 500}
+	if sum.Latency.P50 != expected.P50 || sum.Latency.P90 != expected.P90 ||
+		sum.Latency.P99 != expected.P99 || sum.Latency.Max != expected.Max || sum.Latency.Min != expected.Min {
+		t.Errorf("Expected latencies %+v, got: %+v", expected, sum.Latency)
+	}
+	if sum.Calls != 101 || sum.Errors != 1 || sum.Throughput != 50.5 {
+		t.Errorf("Expected 101 calls, 1 error and 50.5 calls/sec, got: %+v", sum)
+	}
+
+	w := new(bytes.Buffer)
+	err := writeLoadSummary(w, sum, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"  Calls:     101",
+		"  p99:  100.000ms",
+		"  200: 100",
+		"  test error: 1",
+	} {
+		if !strings.Contains(w.String(), line+"\n") {
+			t.Errorf("Expected summary to contain %q, got:\n%s", line, w.String())
+		}
+	}
+}
+
+func TestRunLoad(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  loadOptions
+		calls int
+	}{
+		{name: "test1", opts: loadOptions{concurrency: 1, total: 5}, calls: 5},
+		{name: "test2", opts: loadOptions{concurrency: 4, total: 50}, calls: 50},
+		{name: "test3", opts: loadOptions{concurrency: 4, duration: 100 * time.Millisecond, rate: 40}, calls: 4},
+		{name: "test4", opts: loadOptions{concurrency: 2, total: 20, rate: 3e9}, calls: 20},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := make(chan int, 100)
+			runLoad(tc.opts, func(worker int) { calls <- worker })
+			if tc.opts.duration == 0 && len(calls) != tc.calls {
+				t.Errorf("Expected %d calls, got: %d", tc.calls, len(calls))
+			}
+			if tc.opts.duration != 0 && (len(calls) < 1 || len(calls) > tc.calls+1) {
+				t.Errorf("Expected at most %d calls, got: %d", tc.calls+1, len(calls))
+			}
+		})
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	stats := newLoadStats()
+	for _, ms := range []int{10, 11, 12, 15, 20, 30, 110} {
+		stats.record(time.Duration(ms)*time.Millisecond, "OK", nil)
+	}
+	sum := stats.summary()
+
+	counts := make([]int, len(sum.Histogram))
+	for i, b := range sum.Histogram {
+		counts[i] = b.Count
+	}
+	expected := []int{4, 1, 1, 0, 0, 0, 0, 0, 0, 1}
+	if diff := cmp.Diff(expected, counts); diff != "" {
+		t.Errorf("Histogram mismatch (-want +got):\n%s", diff)
+	}
+	if sum.Histogram[0].UpperBound != 20 || sum.Histogram[9].UpperBound != 110 {
+		t.Errorf("Expected buckets from 20ms to 110ms, got: %+v", sum.Histogram)
+	}
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A config file holds named profiles in sections, with one option per line:
+//
+//	# Comments start with # or ;
+//	[staging]
+//	url = https://staging.example.com/api
+//	target = staging.example.com:443
+//	header = Authorization=Bearer ${STAGING_TOKEN}
+//	timeout = 5s
+//	cacert = ~/certs/staging-ca.pem
+//
+// Options are named after the flags of mync http and mync grpc, options a
+// sub-command has no flag for are ignored. url is the base URL that mync http
+// resolves relative URLs against and target the server mync grpc connects to
+// when none is given. Environment variables in values are expanded.
+
+// configEnv overrides the location of the config file.
+const configEnv = "MYNC_CONFIG"
+
+func configPath() (string, error) {
+	if path := os.Getenv(configEnv); len(path) != 0 {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mync", "config"), nil
+}
+
+type profileEntry struct {
+	key   string
+	value string
+}
+
+type profile struct {
+	name    string
+	entries []profileEntry
+}
+
+// get returns the last value of key.
+func (p *profile) get(key string) (string, bool) {
+	for i := len(p.entries) - 1; i >= 0; i-- {
+		if p.entries[i].key == key {
+			return p.entries[i].value, true
+		}
+	}
+	return "", false
+}
+
+func parseProfiles(r io.Reader, path string) (map[string]*profile, error) {
+	profiles := map[string]*profile{}
+	var current *profile
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			if profiles[name] == nil {
+				profiles[name] = &profile{name: name}
+			}
+			current = profiles[name]
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || len(key) == 0 || current == nil {
+			return nil, fmt.Errorf("%w: %s line %d", ErrInvalidConfig, path, line)
+		}
+		if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+		current.entries = append(current.entries, profileEntry{key: key, value: value})
+	}
+	return profiles, scanner.Err()
+}
+
+// expandValue replaces environment variables in a profile value and a
+// leading ~ with the home directory. Unset variables are an error so that a
+// missing secret is not silently sent as an empty string.
+func expandValue(value string) (string, error) {
+	var missing []string
+	value = os.Expand(value, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) != 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	if strings.HasPrefix(value, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		value = filepath.Join(home, value[2:])
+	}
+	return value, nil
+}
+
+// loadProfile reads the named profile from the config file.
+func loadProfile(name string) (*profile, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	profiles, err := parseProfiles(f, path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q in %s", ErrUnknownProfile, name, path)
+	}
+	for i, e := range p.entries {
+		p.entries[i].value, err = expandValue(e.value)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s, option %s: %w", name, e.key, err)
+		}
+	}
+	return p, nil
+}
+
+// apply sets the flags of fs that were not given on the command line, as
+// recorded in set, to the values of the profile. The key=value options in
+// repeatable, such as headers, are put before those given on the command
+// line, unless the command line has one with the same key.
+func (p *profile) apply(fs *flag.FlagSet, set map[string]bool, repeatable map[string]*[]string) error {
+	for name, values := range repeatable {
+		given := map[string]bool{}
+		for _, v := range *values {
+			key, _, _ := strings.Cut(v, "=")
+			given[strings.ToLower(key)] = true
+		}
+		var fromProfile []string
+		for _, e := range p.entries {
+			key, _, _ := strings.Cut(e.value, "=")
+			if e.key == name && !given[strings.ToLower(key)] {
+				fromProfile = append(fromProfile, e.value)
+			}
+		}
+		*values = append(fromProfile, *values...)
+	}
+
+	for _, e := range p.entries {
+		if _, ok := repeatable[e.key]; ok || set[e.key] || fs.Lookup(e.key) == nil {
+			continue
+		}
+		err := fs.Set(e.key, e.value)
+		if err != nil {
+			return fmt.Errorf("profile %s, option %s: %w", p.name, e.key, err)
+		}
+	}
+	return nil
+}
+
+// resolveURL resolves a URL given on the command line against the base URL
+// of a profile, unless it is absolute.
+func resolveURL(base, u string) string {
+	if len(base) == 0 || strings.Contains(u, "://") {
+		return u
+	}
+	if len(u) == 0 {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(u, "/")
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	svc "service"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+)
+
+func writeConfig(t *testing.T, config string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(path, []byte(config), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(configEnv, path)
+}
+
+func TestParseProfiles(t *testing.T) {
+	config := `
+# Shared defaults
+[staging]
+url = https://staging.example.com/api
+header = Authorization=Bearer ${TOKEN}
+; quoted values keep their spaces
+write-out = " {{.StatusCode}} "
+
+[local]
+target = localhost:50051
+[staging]
+timeout = 5s
+`
+	profiles, err := parseProfiles(strings.NewReader(config), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []profileEntry{
+		{key: "url", value: "https://staging.example.com/api"},
+		{key: "header", value: "Authorization=Bearer ${TOKEN}"},
+		{key: "write-out", value: " {{.StatusCode}} "},
+		{key: "timeout", value: "5s"},
+	}
+	if diff := cmp.Diff(expected, profiles["staging"].entries, cmp.AllowUnexported(profileEntry{})); diff != "" {
+		t.Errorf("Unexpected staging profile (-want +got):\n%s", diff)
+	}
+	if target, _ := profiles["local"].get("target"); target != "localhost:50051" {
+		t.Errorf("Expected the local target, got %q", target)
+	}
+
+	for _, invalid := range []string{"url = http://localhost", "[staging]\nurl"} {
+		_, err = parseProfiles(strings.NewReader(invalid), "config")
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Expected ErrInvalidConfig for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		base, url, expected string
+	}{
+		{"", "http://localhost/a", "http://localhost/a"},
+		{"http://localhost/api/", "/users", "http://localhost/api/users"},
+		{"http://localhost/api", "users?id=1", "http://localhost/api/users?id=1"},
+		{"http://localhost/api", "", "http://localhost/api"},
+		{"http://localhost/api", "https://example.com", "https://example.com"},
+	}
+	for _, tc := range tests {
+		if got := resolveURL(tc.base, tc.url); got != tc.expected {
+			t.Errorf("resolveURL(%q, %q) = %q, expected %q", tc.base, tc.url, got, tc.expected)
+		}
+	}
+}
+
+func TestHandleHttpProfile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s auth=%s env=%s", r.Method, r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("X-Env"))
+	}))
+	defer ts.Close()
+
+	t.Setenv("MYNC_TEST_TOKEN", "s3cret")
+	writeConfig(t, fmt.Sprintf(`
+[staging]
+url = %s/api
+header = Authorization=Bearer ${MYNC_TEST_TOKEN}
+header = X-Env=staging
+verb = POST
+body = {}
+timeout = 2s
+
+[broken]
+url = %s
+header = Authorization=${MYNC_TEST_UNSET}
+`, ts.URL, ts.URL))
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			args:   []string{"-profile", "staging", "/users"},
+			output: "POST /api/users auth=Bearer s3cret env=staging\n",
+		},
+		{
+			name:   "test2",
+			args:   []string{"-profile", "staging", "-verb", "PUT", "-header", "x-env=dev"},
+			output: "PUT /api auth=Bearer s3cret env=dev\n",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-profile", "staging", ts.URL + "/other"},
+			output: "POST /other auth=Bearer s3cret env=staging\n",
+		},
+		{
+			name:   "test4",
+			args:   []string{"-profile", "broken", "/"},
+			errMsg: "profile broken, option header: environment variable MYNC_TEST_UNSET is not set",
+		},
+		{
+			name:   "test5",
+			args:   []string{"-profile", "missing", "/"},
+			errMsg: "unknown profile",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, tc.args)
+			if len(tc.errMsg) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tc.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != tc.output {
+				t.Errorf("Expected output %q, got %q", tc.output, w.String())
+			}
+		})
+	}
+}
+
+func TestHandleGrpcProfile(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := grpc.NewServer()
+	defer s.Stop()
+	svc.RegisterUsersServer(s, &dummyUserService{})
+	svc.RegisterRepoServer(s, &dummyReposService{})
+	go func() {
+		s.Serve(l)
+	}()
+
+	writeConfig(t, fmt.Sprintf(`
+[local]
+target = %s
+service = Users
+method = GetUser
+`, l.Addr()))
+
+	w := new(bytes.Buffer)
+	err = HandleGrpc(w, []string{"-profile", "local", "-request", `{"email":"john@doe.com","id":"user-123"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.String(), `"firstName":"john"`) {
+		t.Errorf("Expected the user from the profile's target, got: %s", w.String())
+	}
+
+	w.Reset()
+	err = HandleGrpc(w, []string{"list", "-profile", "local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "Users.GetUser unary\n" {
+		t.Errorf("Expected the methods of the profile's service, got: %q", w.String())
+	}
+
+	// The only argument of describe is the symbol on the profile's target.
+	w.Reset()
+	err = HandleGrpc(w, []string{"describe", "-profile", "local", "UserGetRequest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "// UserGetRequest (users.proto)\nmessage UserGetRequest {\n  string email = 1;\n  string id = 2;\n}\n"
+	if w.String() != expected {
+		t.Errorf("Expected the message of the profile's target, got: %q", w.String())
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJsonQuery(t *testing.T) {
+	doc := `{"repo":[{"id":"1","owner":{"id":"user-1"},"stars":3,"tags":["a","b"]},{"id":"2","owner":{"id":"user-2"},"stars":10,"archived":true,"tags":null}]}`
+	tests := []struct {
+		name   string
+		query  string
+		doc    string
+		output string
+		errMsg string
+	}{
+		{name: "test1", query: ".repo[0].owner.id", output: "user-1\n"},
+		{name: "test2", query: ".repo[*].id", output: "1\n2\n"},
+		{name: "test3", query: ".repo[].stars", output: "3\n10\n"},
+		{name: "test4", query: ".repo[-1].archived", output: "true\n"},
+		{name: "test5", query: ".repo[0].tags", output: `["a","b"]` + "\n"},
+		{name: "test6", query: ".repo[1].tags", output: "null\n"},
+		{name: "test7", query: ".", doc: `{"a":1}`, output: `{"a":1}` + "\n"},
+		{name: "test8", query: "[1]", doc: `["x","y"]`, output: "y\n"},
+		{name: "test9", query: ".repo[0].name", errMsg: `no match for query ".repo[0].name": .repo[0].name does not exist`},
+		{name: "test10", query: ".repo[2].id", errMsg: `no match for query ".repo[2].id": .repo[2] is out of range for 2 elements`},
+		{name: "test11", query: ".repo.id", errMsg: `no match for query ".repo.id": .repo.id is applied to an array, not an object`},
+		{name: "test12", query: ".repo[0].id[0]", errMsg: `no match for query ".repo[0].id[0]": .repo[0].id[0] is applied to a string, not an array`},
+		{name: "test13", query: ".repo", doc: "not json", errMsg: ErrQueryNotJson.Error()},
+		{name: "test14", query: "repo", errMsg: `invalid query "repo": must start with . or [`},
+		{name: "test15", query: ".repo[x]", errMsg: `invalid query ".repo[x]": invalid index "x"`},
+		{name: "test16", query: ".repo[0", errMsg: `invalid query ".repo[0": missing ]`},
+		{name: "test17", query: ".repo..id", errMsg: `invalid query ".repo..id": empty field name`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.doc) == 0 {
+				tc.doc = doc
+			}
+			w := new(bytes.Buffer)
+			q, err := parseQuery(tc.query)
+			if err == nil {
+				err = writeQuery(w, q, []byte(tc.doc))
+			}
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.HasPrefix(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if w.String() != tc.output {
+				t.Errorf("Expected output %q, got %q", tc.output, w.String())
+			}
+		})
+	}
+}
+
+func TestQueryResponses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"repo":[{"id":"1","owner":{"id":"user-1"}}]}`)
+	}))
+	defer ts.Close()
+
+	w := new(bytes.Buffer)
+	err := HandleHttp(w, []string{"-query", ".repo[0].owner.id", ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "user-1\n" {
+		t.Errorf("Expected user-1, got: %q", w.String())
+	}
+
+	err = HandleHttp(w, []string{"-query", ".repo[0].name", ts.URL})
+	if err == nil || !strings.Contains(err.Error(), ErrQueryNoMatch.Error()) {
+		t.Errorf("Expected error: %v, got: %v", ErrQueryNoMatch, err)
+	}
+
+	s, l := startTestStreamServer()
+	defer s.GracefulStop()
+	conn := dialTestServer(t, l)
+	defer conn.Close()
+
+	origStderr := stderr
+	defer func() { stderr = origStderr }()
+	stderr = new(bytes.Buffer)
+
+	w.Reset()
+	q, err := parseQuery(".repo.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := grpcConfig{service: "Repo", method: "GetRepos", request: `{"id":"1","creatorId":"user-1"}`, query: q}
+	err = callMethod(context.Background(), conn, c, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "repo-1\nrepo-2\nrepo-3\nrepo-4\nrepo-5\n"
+	if w.String() != expected {
+		t.Errorf("Expected %q, got: %q", expected, w.String())
+	}
+
+	w.Reset()
+	c = grpcConfig{service: "Users", method: "GetUser", request: `{"email":"jane@doe.com","id":"1"}`, query: q}
+	err = callMethod(context.Background(), conn, c, w)
+	if err == nil || !strings.Contains(err.Error(), `.repo does not exist`) {
+		t.Errorf("Expected error: %v, got: %v", ErrQueryNoMatch, err)
+	}
+
+	// Fields with default values can be selected too.
+	w.Reset()
+	q, err = parseQuery(".user.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = grpcConfig{service: "Users", method: "GetUser", request: `{"email":"jane@doe.com"}`, query: q}
+	err = callMethod(context.Background(), conn, c, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "\n" {
+		t.Errorf("Expected an empty id, got: %q", w.String())
+	}
+}
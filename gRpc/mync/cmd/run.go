@@ -0,0 +1,457 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// scenario is a YAML file of steps run in order by mync run, for example:
+//
+//	name: repos
+//	vars:
+//	  api: http://localhost:8080
+//	  target: localhost:50051
+//	steps:
+//	  - name: create a repo
+//	    http: [-verb, POST, -body, '{"name":"mync"}', '{{.api}}/repos']
+//	    expect:
+//	      status: 201
+//	      headers: {Content-Type: application/json}
+//	    capture:
+//	      repoId: .id
+//	  - name: fetch it
+//	    grpc: [-service, Repo, -method, GetRepos, -request, '{"id":"{{.repoId}}"}', '{{.target}}']
+//	    expect:
+//	      code: OK
+//	      json: {'.repo[0].name': mync}
+//
+// The arguments of a step are those of mync http or mync grpc, and are
+// templates of the variables captured so far.
+type scenario struct {
+	Name  string            `yaml:"name"`
+	Vars  map[string]string `yaml:"vars"`
+	Steps []scenarioStep    `yaml:"steps"`
+}
+
+type scenarioStep struct {
+	Name    string            `yaml:"name"`
+	HTTP    []string          `yaml:"http"`
+	Grpc    []string          `yaml:"grpc"`
+	Expect  stepExpectations  `yaml:"expect"`
+	Capture map[string]string `yaml:"capture"`
+}
+
+// stepExpectations are checked against the response of a step. JSON maps
+// queries such as .repo[0].id, see -query, to the values they select. A
+// gRPC step fails on an error status unless Code is set.
+type stepExpectations struct {
+	Status  int               `yaml:"status"`
+	Code    string            `yaml:"code"`
+	Headers map[string]string `yaml:"headers"`
+	JSON    map[string]any    `yaml:"json"`
+}
+
+// stepResponse is what a step received. The body of a gRPC step is the
+// response it printed, or an array of them if it printed several.
+type stepResponse struct {
+	status int
+	header http.Header
+	code   codes.Code
+	body   []byte
+}
+
+// stepResult is a step that was run, with the reasons it failed.
+type stepResult struct {
+	suite    string
+	name     string
+	duration time.Duration
+	failures []string
+	output   string
+}
+
+type runConfig struct {
+	format string
+	vars   []string
+}
+
+func HandleRun(w io.Writer, args []string) error {
+	var c runConfig
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.StringVar(&c.format, "format", "tap", "Format of the report (tap or junit)")
+	fs.Func("var", "Set a variable of the scenarios, overriding their vars (key=value)", func(s string) error {
+		c.vars = append(c.vars, s)
+		return nil
+	})
+	fs.Usage = func() {
+		var usageString = `
+run: Run scenarios of HTTP and gRPC steps with assertions.
+
+run: <options> scenario.yaml...`
+		fmt.Fprint(w, usageString)
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Options: ")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return FlagParsingError{err}
+	}
+	if fs.NArg() == 0 {
+		return InvalidInputError{ErrNoScenario}
+	}
+	if c.format != "tap" && c.format != "junit" {
+		return InvalidInputError{ErrInvalidRunFormat}
+	}
+	vars := map[string]string{}
+	for _, v := range c.vars {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || len(key) == 0 {
+			return InvalidInputError{fmt.Errorf("%w: %q", ErrInvalidVar, v)}
+		}
+		vars[key] = value
+	}
+
+	var scenarios []*scenario
+	for _, path := range fs.Args() {
+		s, err := loadScenario(path)
+		if err != nil {
+			return err
+		}
+		scenarios = append(scenarios, s)
+	}
+
+	var results [][]stepResult
+	var steps, failed int
+	for _, s := range scenarios {
+		suite := s.run(vars)
+		for _, r := range suite {
+			steps++
+			if len(r.failures) != 0 {
+				failed++
+			}
+		}
+		results = append(results, suite)
+	}
+
+	if c.format == "junit" {
+		err = writeJUnit(w, scenarios, results)
+	} else {
+		writeTAP(w, results)
+	}
+	if err != nil {
+		return err
+	}
+	if failed != 0 {
+		return fmt.Errorf("%w: %d of %d steps failed", ErrScenarioFailed, failed, steps)
+	}
+	return nil
+}
+
+func loadScenario(path string) (*scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s scenario
+	err = yaml.Unmarshal(data, &s)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidScenario, path, err)
+	}
+	if len(s.Name) == 0 {
+		s.Name = path
+	}
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if (step.HTTP == nil) == (step.Grpc == nil) {
+			return nil, fmt.Errorf("%w %s: step %d must have one of http and grpc", ErrInvalidScenario, path, i+1)
+		}
+		if len(step.Name) == 0 {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+		if len(step.Expect.Code) != 0 {
+			_, err := parseCode(step.Expect.Code)
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: step %d: %w", ErrInvalidScenario, path, i+1, err)
+			}
+		}
+		for query := range step.Expect.JSON {
+			_, err := parseQuery(query)
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: step %d: %w", ErrInvalidScenario, path, i+1, err)
+			}
+		}
+		for _, source := range step.Capture {
+			if strings.HasPrefix(source, "header:") {
+				continue
+			}
+			_, err := parseQuery(source)
+			if err != nil {
+				return nil, fmt.Errorf("%w %s: step %d: %w", ErrInvalidScenario, path, i+1, err)
+			}
+		}
+	}
+	return &s, nil
+}
+
+// run runs the steps of s in order with its variables overridden by vars.
+func (s *scenario) run(vars map[string]string) []stepResult {
+	data := map[string]string{}
+	for k, v := range s.Vars {
+		data[k] = v
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+
+	var results []stepResult
+	for _, step := range s.Steps {
+		start := time.Now()
+		output := new(bytes.Buffer)
+		failures := step.run(output, data)
+		results = append(results, stepResult{
+			suite:    s.Name,
+			name:     step.Name,
+			duration: time.Since(start),
+			failures: failures,
+			output:   output.String(),
+		})
+	}
+	return results
+}
+
+// run runs the step, printing its output to w, and checks its response,
+// adding the values it captures to vars.
+func (step *scenarioStep) run(w *bytes.Buffer, vars map[string]string) []string {
+	args := step.HTTP
+	if step.Grpc != nil {
+		args = step.Grpc
+	}
+	args, err := expandArgs(args, vars)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var resp *stepResponse
+	if step.HTTP != nil {
+		resp, err = runHttpStep(w, args)
+	} else {
+		resp, err = runGrpcStep(w, args)
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	failures := step.Expect.check(resp)
+	names := make([]string, 0, len(step.Capture))
+	for name := range step.Capture {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := resp.capture(step.Capture[name])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("capture %s: %v", name, err))
+			continue
+		}
+		vars[name] = value
+	}
+	return failures
+}
+
+func expandArgs(args []string, vars map[string]string) ([]string, error) {
+	funcs := template.FuncMap{"env": os.Getenv}
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		t, err := template.New("arg").Funcs(funcs).Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		err = t.Execute(&b, vars)
+		if err != nil {
+			return nil, err
+		}
+		expanded[i] = b.String()
+	}
+	return expanded, nil
+}
+
+func runHttpStep(w io.Writer, args []string) (*stepResponse, error) {
+	var resp *stepResponse
+	err := handleHttp(w, args, func(r *http.Response, body []byte) {
+		resp = &stepResponse{status: r.StatusCode, header: r.Header, body: body}
+	})
+	var statusErr HTTPStatusError
+	if err != nil && !errors.As(err, &statusErr) {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("no response was received")
+	}
+	return resp, nil
+}
+
+func runGrpcStep(w io.Writer, args []string) (*stepResponse, error) {
+	output := new(bytes.Buffer)
+	err := handleGrpc(io.MultiWriter(w, output), args, true)
+	resp := &stepResponse{code: codes.OK}
+	if err != nil {
+		s, ok := status.FromError(err)
+		if !ok {
+			return nil, err
+		}
+		resp.code = s.Code()
+	}
+
+	var responses []json.RawMessage
+	d := json.NewDecoder(output)
+	for {
+		var r json.RawMessage
+		err := d.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Not JSON, e.g. printed with -query, JSON assertions fail.
+			resp.body = output.Bytes()
+			return resp, nil
+		}
+		responses = append(responses, r)
+	}
+	switch len(responses) {
+	case 0:
+	case 1:
+		resp.body = responses[0]
+	default:
+		resp.body, err = json.Marshal(responses)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (e stepExpectations) check(resp *stepResponse) []string {
+	var failures []string
+	if e.Status != 0 && e.Status != resp.status {
+		failures = append(failures, fmt.Sprintf("status: expected %d, got %d", e.Status, resp.status))
+	}
+	if len(e.Code) != 0 {
+		code, _ := parseCode(e.Code)
+		if code != resp.code {
+			failures = append(failures, fmt.Sprintf("code: expected %s, got %s", code, resp.code))
+		}
+	} else if resp.code != codes.OK {
+		failures = append(failures, fmt.Sprintf("code: expected OK, got %s", resp.code))
+	}
+
+	names := make([]string, 0, len(e.Headers))
+	for name := range e.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		got := resp.header.Get(name)
+		if got != e.Headers[name] {
+			failures = append(failures, fmt.Sprintf("header %s: expected %q, got %q", name, e.Headers[name], got))
+		}
+	}
+
+	queries := make([]string, 0, len(e.JSON))
+	for query := range e.JSON {
+		queries = append(queries, query)
+	}
+	sort.Strings(queries)
+	for _, query := range queries {
+		err := checkJson(resp.body, query, e.JSON[query])
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("json %s: %v", query, err))
+		}
+	}
+	return failures
+}
+
+// checkJson compares the value query selects in body with expected, or
+// the list of values if the query selects every element of an array.
+func checkJson(body []byte, query string, expected any) error {
+	q, err := parseQuery(query)
+	if err != nil {
+		return err
+	}
+	values, err := q.apply(body)
+	if err != nil {
+		return err
+	}
+	var got any = values
+	if len(values) == 1 && !slices.ContainsFunc(q.segments, func(s querySegment) bool { return s.all }) {
+		got = values[0]
+	}
+	gotJson, err := normalizeJson(got)
+	if err != nil {
+		return err
+	}
+	expectedJson, err := normalizeJson(expected)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(gotJson, expectedJson) {
+		got, _ := json.Marshal(gotJson)
+		want, _ := json.Marshal(expectedJson)
+		return fmt.Errorf("expected %s, got %s", want, got)
+	}
+	return nil
+}
+
+// normalizeJson converts v to the values encoding/json decodes, so that
+// YAML and JSON numbers compare equal.
+func normalizeJson(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// capture returns the value source selects, a header with header:Name or
+// the value of a JSON query, strings unquoted and anything else as JSON.
+func (resp *stepResponse) capture(source string) (string, error) {
+	if name, ok := strings.CutPrefix(source, "header:"); ok {
+		values := resp.header.Values(name)
+		if len(values) == 0 {
+			return "", fmt.Errorf("no %s header", name)
+		}
+		return values[0], nil
+	}
+	q, err := parseQuery(source)
+	if err != nil {
+		return "", err
+	}
+	out := new(bytes.Buffer)
+	err = writeQuery(out, q, resp.body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out.String(), "\n"), nil
+}
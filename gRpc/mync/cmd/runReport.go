@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// writeTAP prints the results in the Test Anything Protocol, version 13,
+// with the failures and output of failed steps in a YAML block.
+func writeTAP(w io.Writer, results [][]stepResult) {
+	var total int
+	for _, suite := range results {
+		total += len(suite)
+	}
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d\n", total)
+	n := 0
+	for _, suite := range results {
+		for _, r := range suite {
+			n++
+			name := strings.ReplaceAll(r.suite+": "+r.name, "#", `\#`)
+			if len(r.failures) == 0 {
+				fmt.Fprintf(w, "ok %d - %s\n", n, name)
+				continue
+			}
+			fmt.Fprintf(w, "not ok %d - %s\n", n, name)
+			fmt.Fprintln(w, "  ---")
+			fmt.Fprintln(w, "  failures:")
+			for _, f := range r.failures {
+				fmt.Fprintf(w, "    - %q\n", f)
+			}
+			if len(r.output) != 0 {
+				fmt.Fprintln(w, "  output: |")
+				for _, line := range strings.Split(strings.TrimSuffix(r.output, "\n"), "\n") {
+					fmt.Fprintf(w, "    %s\n", line)
+				}
+			}
+			fmt.Fprintln(w, "  ...")
+		}
+	}
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnit prints the results as JUnit XML, a test suite per scenario.
+func writeJUnit(w io.Writer, scenarios []*scenario, results [][]stepResult) error {
+	var report junitTestSuites
+	for i, suite := range results {
+		ts := junitTestSuite{Name: scenarios[i].Name, Tests: len(suite)}
+		var total time.Duration
+		for _, r := range suite {
+			total += r.duration
+			tc := junitTestCase{Name: r.name, ClassName: r.suite, Time: junitTime(r.duration)}
+			if len(r.failures) != 0 {
+				ts.Failures++
+				tc.Failure = &junitFailure{
+					Message: r.failures[0],
+					Text:    strings.Join(r.failures, "\n"),
+				}
+				tc.SystemOut = r.output
+			}
+			ts.Cases = append(ts.Cases, tc)
+		}
+		ts.Time = junitTime(total)
+		report.Suites = append(report.Suites, ts)
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, xml.Header)
+	fmt.Fprintln(w, string(data))
+	return nil
+}
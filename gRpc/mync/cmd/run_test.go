@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const runTestStubs = `
+http:
+  - method: POST
+    path: /repos
+    response:
+      status: 201
+      headers: {Location: /repos/42}
+      body: {id: "42", name: mync}
+grpc:
+  - method: Repo/GetRepos
+    request: {id: "42"}
+    response: {repo: [{id: "42", name: mync}]}
+  - method: Repo/GetRepos
+    error: {code: NotFound, message: no such repo}
+`
+
+const runTestScenario = `
+name: repos
+vars:
+  api: http://localhost:1
+steps:
+  - name: create a repo
+    http: [-verb, POST, -body, '{"name":"mync"}', '{{.api}}/repos']
+    expect:
+      status: 201
+      headers: {Content-Type: application/json}
+      json: {.name: mync}
+    capture:
+      repoId: .id
+      location: header:Location
+  - name: fetch it
+    grpc: [-service, Repo, -method, GetRepos, -request, '{"id":"{{.repoId}}"}', '{{.target}}']
+    expect:
+      json: {'.repo[0].name': mync, '.repo[*].id': ["42"], '.repo[0].url': ""}
+  - name: fetch a missing one
+    grpc: [-service, Repo, -method, GetRepos, -request, '{"id":"{{.location}}"}', '{{.target}}']
+    expect:
+      code: NOT_FOUND
+`
+
+func writeScenario(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	err := os.WriteFile(path, []byte(text), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHandleRun(t *testing.T) {
+	httpAddr, grpcAddr := startStubServer(t, runTestStubs, nil)
+	scenario := writeScenario(t, runTestScenario)
+	vars := []string{"-var", "api=http://" + httpAddr, "-var", "target=" + grpcAddr}
+
+	w := new(bytes.Buffer)
+	err := HandleRun(w, append(vars, scenario))
+	if err != nil {
+		t.Fatal(err, w.String())
+	}
+	expected := `TAP version 13
+1..3
+ok 1 - repos: create a repo
+ok 2 - repos: fetch it
+ok 3 - repos: fetch a missing one
+`
+	if diff := cmp.Diff(expected, w.String()); diff != "" {
+		t.Errorf("Unexpected TAP report (-want +got):\n%s", diff)
+	}
+
+	failing := writeScenario(t, `
+steps:
+  - name: create a repo
+    http: [-verb, POST, -body, '{}', '{{.api}}/repos']
+    expect:
+      status: 200
+      json: {.name: other}
+    capture:
+      repoId: .missing
+  - grpc: [-service, Repo, -method, GetRepos, -request, '{"id":"{{.repoId}}"}', '{{.target}}']
+  - grpc: [-service, Repo, -method, GetRepos, -request, '{"id":"1"}', '{{.target}}']
+`)
+	w = new(bytes.Buffer)
+	err = HandleRun(w, append(vars, failing))
+	if !errors.Is(err, ErrScenarioFailed) || !strings.Contains(err.Error(), "3 of 3 steps failed") {
+		t.Fatalf("Expected ErrScenarioFailed, got %v", err)
+	}
+	expected = fmt.Sprintf(`TAP version 13
+1..3
+not ok 1 - %[1]s: create a repo
+  ---
+  failures:
+    - "status: expected 200, got 201"
+    - "json .name: expected \"other\", got \"mync\""
+    - "capture repoId: no match for query \".missing\": .missing does not exist"
+  output: |
+    {"id":"42","name":"mync"}
+  ...
+not ok 2 - %[1]s: step 2
+  ---
+  failures:
+    - "template: arg:1:9: executing \"arg\" at <.repoId>: map has no entry for key \"repoId\""
+  ...
+not ok 3 - %[1]s: step 3
+  ---
+  failures:
+    - "code: expected OK, got NotFound"
+  ...
+`, failing)
+	if diff := cmp.Diff(expected, w.String()); diff != "" {
+		t.Errorf("Unexpected TAP report (-want +got):\n%s", diff)
+	}
+
+	w = new(bytes.Buffer)
+	err = HandleRun(w, append(vars, "-format", "junit", scenario, failing))
+	if !errors.Is(err, ErrScenarioFailed) || !strings.Contains(err.Error(), "3 of 6 steps failed") {
+		t.Fatalf("Expected ErrScenarioFailed, got %v", err)
+	}
+	report := regexp.MustCompile(`time="[0-9.]+"`).ReplaceAllString(w.String(), `time="0"`)
+	expected = fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="repos" tests="3" failures="0" time="0">
+    <testcase name="create a repo" classname="repos" time="0"></testcase>
+    <testcase name="fetch it" classname="repos" time="0"></testcase>
+    <testcase name="fetch a missing one" classname="repos" time="0"></testcase>
+  </testsuite>
+  <testsuite name="%[1]s" tests="3" failures="3" time="0">
+    <testcase name="create a repo" classname="%[1]s" time="0">
+      <failure message="status: expected 200, got 201">status: expected 200, got 201&#xA;json .name: expected &#34;other&#34;, got &#34;mync&#34;&#xA;capture repoId: no match for query &#34;.missing&#34;: .missing does not exist</failure>
+      <system-out>{&#34;id&#34;:&#34;42&#34;,&#34;name&#34;:&#34;mync&#34;}&#xA;</system-out>
+    </testcase>
+    <testcase name="step 2" classname="%[1]s" time="0">
+      <failure message="template: arg:1:9: executing &#34;arg&#34; at &lt;.repoId&gt;: map has no entry for key &#34;repoId&#34;">template: arg:1:9: executing &#34;arg&#34; at &lt;.repoId&gt;: map has no entry for key &#34;repoId&#34;</failure>
+    </testcase>
+    <testcase name="step 3" classname="%[1]s" time="0">
+      <failure message="code: expected OK, got NotFound">code: expected OK, got NotFound</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, failing)
+	if diff := cmp.Diff(expected, report); diff != "" {
+		t.Errorf("Unexpected JUnit report (-want +got):\n%s", diff)
+	}
+}
+
+func TestHandleRunInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		scenario string
+		errMsg   string
+	}{
+		{name: "test1", args: []string{}, errMsg: ErrNoScenario.Error()},
+		{name: "test2", args: []string{"-format", "xml"}, scenario: "steps: []", errMsg: ErrInvalidRunFormat.Error()},
+		{name: "test3", args: []string{"-var", "novalue"}, scenario: "steps: []", errMsg: `vars must be key=value: "novalue"`},
+		{name: "test4", scenario: "steps: [{name: nothing}]", errMsg: "step 1 must have one of http and grpc"},
+		{name: "test5", scenario: "steps: [{http: [x], grpc: [y]}]", errMsg: "step 1 must have one of http and grpc"},
+		{name: "test6", scenario: "steps: [{grpc: [x], expect: {code: Broken}}]", errMsg: `step 1: unknown status code "Broken"`},
+		{name: "test7", scenario: "steps: [{http: [x], capture: {id: id}}]", errMsg: `step 1: invalid query "id"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := tc.args
+			if len(tc.scenario) != 0 {
+				args = append(args, writeScenario(t, tc.scenario))
+			}
+			err := HandleRun(new(bytes.Buffer), args)
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Errorf("Expected error: %q, got: %v", tc.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type serveConfig struct {
+	stubs    string
+	httpAddr string
+	grpcAddr string
+	protoset string
+}
+
+// stubServer answers HTTP requests and gRPC calls with the stubs of a stub
+// file, logging each of them.
+type stubServer struct {
+	stubs  *stubFile
+	files  *protoregistry.Files
+	logger *log.Logger
+}
+
+func HandleServe(w io.Writer, args []string) error {
+	var c serveConfig
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(w)
+	fs.StringVar(&c.stubs, "stubs", "", "YAML file with the HTTP and gRPC stubs to serve")
+	fs.StringVar(&c.httpAddr, "http-addr", "localhost:8080", "Address to serve the HTTP stubs on")
+	fs.StringVar(&c.grpcAddr, "grpc-addr", "localhost:50051", "Address to serve the gRPC stubs on")
+	fs.StringVar(&c.protoset, "protoset", "", "File descriptor set to take the services from instead of the Users and Repo protos of mync")
+	fs.Usage = func() {
+		var usageString = `
+serve: A mock HTTP and gRPC server.
+
+serve: -stubs stubs.yaml <options>`
+		fmt.Fprint(w, usageString)
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Options: ")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return FlagParsingError{err}
+	}
+	if fs.NArg() != 0 || len(c.stubs) == 0 {
+		return InvalidInputError{ErrNoStubs}
+	}
+
+	files, err := localRegistry()
+	if len(c.protoset) != 0 {
+		files, err = readProtoset(c.protoset)
+	}
+	if err != nil {
+		return err
+	}
+	stubs, err := loadStubs(c.stubs, files)
+	if err != nil {
+		return err
+	}
+
+	var httpListener, grpcListener net.Listener
+	if len(stubs.HTTP) != 0 {
+		httpListener, err = net.Listen("tcp", c.httpAddr)
+		if err != nil {
+			return err
+		}
+		defer httpListener.Close()
+	}
+	if len(stubs.Grpc) != 0 {
+		grpcListener, err = net.Listen("tcp", c.grpcAddr)
+		if err != nil {
+			return err
+		}
+		defer grpcListener.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	s := &stubServer{stubs: stubs, files: files, logger: log.New(w, "", log.LstdFlags)}
+	return s.serve(ctx, httpListener, grpcListener)
+}
+
+// serve serves the stubs on the listeners that are not nil until ctx is
+// done.
+func (s *stubServer) serve(ctx context.Context, httpListener, grpcListener net.Listener) error {
+	errs := make(chan error, 2)
+	if httpListener != nil {
+		hs := &http.Server{Handler: s}
+		s.logger.Printf("Serving %d HTTP stubs on %s", len(s.stubs.HTTP), httpListener.Addr())
+		go func() {
+			err := hs.Serve(httpListener)
+			if !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			hs.Shutdown(shutdownCtx)
+		}()
+	}
+	if grpcListener != nil {
+		gs, err := s.grpcServer()
+		if err != nil {
+			return err
+		}
+		s.logger.Printf("Serving %d gRPC stubs on %s", len(s.stubs.Grpc), grpcListener.Addr())
+		go func() {
+			err := gs.Serve(grpcListener)
+			if err != nil {
+				errs <- err
+			}
+		}()
+		defer gs.Stop()
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var stub *httpStub
+	for i := range s.stubs.HTTP {
+		if s.stubs.HTTP[i].matches(r) {
+			stub = &s.stubs.HTTP[i]
+			break
+		}
+	}
+	if stub == nil {
+		s.logger.Printf("HTTP %s %s: no stub", r.Method, r.URL.Path)
+		http.Error(w, fmt.Sprintf("no stub matches %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+		return
+	}
+
+	resp := stub.Response
+	err := sleepContext(r.Context(), resp.Delay)
+	if err != nil {
+		return
+	}
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.body)
+	s.logger.Printf("HTTP %s %s: %d", r.Method, r.URL.Path, resp.Status)
+}
+
+// grpcServer returns a server with every service in s.files and the
+// reflection service registered.
+func (s *stubServer) grpcServer() (*grpc.Server, error) {
+	gs := grpc.NewServer()
+	var err error
+	s.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			if sd.FullName() == protoreflect.FullName(rpb.ServerReflection_ServiceDesc.ServiceName) {
+				err = fmt.Errorf("%w: cannot serve %s", ErrInvalidProtoset, sd.FullName())
+				return false
+			}
+			gsd := grpc.ServiceDesc{ServiceName: string(sd.FullName()), HandlerType: (*any)(nil)}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				gsd.Streams = append(gsd.Streams, grpc.StreamDesc{
+					StreamName: string(md.Name()),
+					Handler: func(srv any, stream grpc.ServerStream) error {
+						err := s.handleCall(md, stream)
+						s.logger.Printf("gRPC %s: %s", methodPath(md), status.Code(err))
+						return err
+					},
+					ServerStreams: md.IsStreamingServer(),
+					ClientStreams: md.IsStreamingClient(),
+				})
+			}
+			gs.RegisterService(&gsd, struct{}{})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	rpb.RegisterServerReflectionServer(gs, reflection.NewServerV1(reflection.ServerOptions{
+		Services:           gs,
+		DescriptorResolver: s.files,
+	}))
+	return gs, nil
+}
+
+// handleCall answers a call with the first stub matching its metadata and
+// first request. Requests of client streams are all read before
+// answering, those of bidi streams after.
+func (s *stubServer) handleCall(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	header, _ := metadata.FromIncomingContext(ctx)
+
+	var request map[string]any
+	in := dynamicpb.NewMessage(md.Input())
+	err := stream.RecvMsg(in)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == nil {
+		request, err = messageFields(in)
+		if err != nil {
+			return err
+		}
+		if md.IsStreamingClient() && !md.IsStreamingServer() {
+			err := drainStream(md, stream)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var stub *grpcStub
+	for i := range s.stubs.Grpc {
+		if s.stubs.Grpc[i].matches(md, header, request) {
+			stub = &s.stubs.Grpc[i]
+			break
+		}
+	}
+	if stub == nil {
+		return status.Errorf(codes.Unimplemented, "no stub matches %s", methodPath(md))
+	}
+
+	err = sleepContext(ctx, stub.Delay)
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	for i, m := range stub.messages {
+		if i > 0 {
+			err := sleepContext(ctx, stub.Interval)
+			if err != nil {
+				return status.FromContextError(err).Err()
+			}
+		}
+		err := stream.SendMsg(m)
+		if err != nil {
+			return err
+		}
+	}
+	if md.IsStreamingClient() && md.IsStreamingServer() {
+		err := drainStream(md, stream)
+		if err != nil {
+			return err
+		}
+	}
+	if stub.Error != nil {
+		return status.Error(stub.Error.code, stub.Error.Message)
+	}
+	return nil
+}
+
+func drainStream(md protoreflect.MethodDescriptor, stream grpc.ServerStream) error {
+	for {
+		err := stream.RecvMsg(dynamicpb.NewMessage(md.Input()))
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext waits for d, or returns the error of ctx if it is done
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"gopkg.in/yaml.v3"
+)
+
+// stubFile is the YAML file mync serve takes its canned responses from, for
+// example:
+//
+//	http:
+//	  - method: GET
+//	    path: /users/*
+//	    headers:
+//	      Authorization: Bearer token
+//	    response:
+//	      status: 200
+//	      body: {"id": "1"}
+//	      delay: 100ms
+//	grpc:
+//	  - method: Repo/GetRepos
+//	    request: {creatorId: "1"}
+//	    response: {repo: [{name: mync}, {name: stream-service}]}
+//	    delay: 50ms
+//	  - method: Repo/GetRepos
+//	    error: {code: Unavailable, message: try again}
+//
+// The first stub matching a request answers it. Server streaming methods,
+// from -protoset, can have several responses, Interval apart.
+type stubFile struct {
+	HTTP []httpStub `yaml:"http"`
+	Grpc []grpcStub `yaml:"grpc"`
+}
+
+// httpStub answers requests with the method, if set, a path matching Path
+// (see path.Match) and the headers listed in Headers.
+type httpStub struct {
+	Method   string            `yaml:"method"`
+	Path     string            `yaml:"path"`
+	Headers  map[string]string `yaml:"headers"`
+	Response httpStubResponse  `yaml:"response"`
+}
+
+// httpStubResponse is sent after Delay. A Body that isn't a string is sent
+// as JSON.
+type httpStubResponse struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    any               `yaml:"body"`
+	Delay   time.Duration     `yaml:"delay"`
+
+	body []byte
+}
+
+// grpcStub answers calls of Method, "Service/Method", with the metadata
+// listed in Metadata and a first request with the fields set in Request.
+// After Delay it sends Response or each of Responses, Interval apart, and
+// then ends the call with Error if set.
+type grpcStub struct {
+	Method    string            `yaml:"method"`
+	Metadata  map[string]string `yaml:"metadata"`
+	Request   map[string]any    `yaml:"request"`
+	Response  map[string]any    `yaml:"response"`
+	Responses []map[string]any  `yaml:"responses"`
+	Delay     time.Duration     `yaml:"delay"`
+	Interval  time.Duration     `yaml:"interval"`
+	Error     *grpcStubStatus   `yaml:"error"`
+
+	md       protoreflect.MethodDescriptor
+	request  map[string]any
+	messages []*dynamicpb.Message
+}
+
+// grpcStubStatus is an error status, Code is a name such as NotFound or
+// NOT_FOUND, or a number.
+type grpcStubStatus struct {
+	Code    string `yaml:"code"`
+	Message string `yaml:"message"`
+
+	code codes.Code
+}
+
+// readProtoset reads a FileDescriptorSet, as written by protoc with
+// --descriptor_set_out and --include_imports.
+func readProtoset(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	err = proto.Unmarshal(data, &set)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProtoset, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProtoset, err)
+	}
+	return files, nil
+}
+
+// localRegistry returns the protos compiled into mync.
+func localRegistry() (*protoregistry.Files, error) {
+	files := new(protoregistry.Files)
+	for _, fd := range localFiles {
+		err := files.RegisterFile(fd)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// loadStubs reads a stub file and checks it against the methods in files.
+func loadStubs(path string, files *protoregistry.Files) (*stubFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stubs stubFile
+	err = yaml.Unmarshal(data, &stubs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidStubs, err)
+	}
+	for i := range stubs.HTTP {
+		err := stubs.HTTP[i].resolve()
+		if err != nil {
+			return nil, fmt.Errorf("%w: http stub %d: %w", ErrInvalidStubs, i+1, err)
+		}
+	}
+	for i := range stubs.Grpc {
+		err := stubs.Grpc[i].resolve(files)
+		if err != nil {
+			return nil, fmt.Errorf("%w: grpc stub %d: %w", ErrInvalidStubs, i+1, err)
+		}
+	}
+	return &stubs, nil
+}
+
+func (s *httpStub) resolve() error {
+	if len(s.Path) == 0 {
+		return fmt.Errorf("path is missing")
+	}
+	_, err := path.Match(s.Path, "")
+	if err != nil {
+		return fmt.Errorf("path %q: %w", s.Path, err)
+	}
+	s.Method = strings.ToUpper(s.Method)
+	r := &s.Response
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	switch body := r.Body.(type) {
+	case nil:
+	case string:
+		r.body = []byte(body)
+	default:
+		r.body, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("body: %w", err)
+		}
+		if _, ok := r.header("Content-Type"); !ok {
+			if r.Headers == nil {
+				r.Headers = map[string]string{}
+			}
+			r.Headers["Content-Type"] = "application/json"
+		}
+	}
+	return nil
+}
+
+func (r *httpStubResponse) header(name string) (string, bool) {
+	for k, v := range r.Headers {
+		if http.CanonicalHeaderKey(k) == name {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (s *httpStub) matches(r *http.Request) bool {
+	if len(s.Method) != 0 && s.Method != r.Method {
+		return false
+	}
+	if ok, _ := path.Match(s.Path, r.URL.Path); !ok {
+		return false
+	}
+	for k, v := range s.Headers {
+		if r.Header.Get(k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *grpcStub) resolve(files *protoregistry.Files) error {
+	service, method, ok := strings.Cut(strings.TrimPrefix(s.Method, "/"), "/")
+	if !ok {
+		return fmt.Errorf("method %q must be Service/Method", s.Method)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	sd, isService := desc.(protoreflect.ServiceDescriptor)
+	if err != nil || !isService {
+		return fmt.Errorf("%w %s", ErrUnrecognizedService, service)
+	}
+	s.md = sd.Methods().ByName(protoreflect.Name(method))
+	if s.md == nil {
+		return fmt.Errorf("%w %s", ErrInvalidGrpcMethod, s.Method)
+	}
+
+	if s.Request != nil {
+		// Through the request message, so that fields can be given by
+		// their proto or JSON names.
+		m := dynamicpb.NewMessage(s.md.Input())
+		err := unmarshalStubMessage(s.Request, m)
+		if err != nil {
+			return fmt.Errorf("request: %w", err)
+		}
+		s.request, err = messageFields(m)
+		if err != nil {
+			return err
+		}
+	}
+
+	responses := s.Responses
+	if s.Response != nil {
+		responses = append([]map[string]any{s.Response}, responses...)
+	}
+	if len(responses) > 1 && !s.md.IsStreamingServer() {
+		return fmt.Errorf("%s is not server streaming, it can only have one response", s.Method)
+	}
+	if len(responses) == 0 && s.Error == nil && !s.md.IsStreamingServer() {
+		return fmt.Errorf("a response or an error is missing")
+	}
+	for _, r := range responses {
+		m := dynamicpb.NewMessage(s.md.Output())
+		err := unmarshalStubMessage(r, m)
+		if err != nil {
+			return fmt.Errorf("response: %w", err)
+		}
+		s.messages = append(s.messages, m)
+	}
+
+	if s.Error != nil {
+		s.Error.code, err = parseCode(s.Error.Code)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalStubMessage(v map[string]any, m *dynamicpb.Message) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(data, m)
+}
+
+// messageFields returns the fields set in m by their JSON names.
+func messageFields(m proto.Message) (map[string]any, error) {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
+
+// parseCode parses a status code by its name, in either Go or proto
+// spelling, or by its number.
+func parseCode(s string) (codes.Code, error) {
+	if n, err := strconv.ParseUint(s, 10, 32); err == nil && n <= uint64(codes.Unauthenticated) {
+		return codes.Code(n), nil
+	}
+	name := strings.ReplaceAll(strings.ToLower(s), "_", "")
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == name {
+			return c, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown status code %q", s)
+}
+
+func (s *grpcStub) matches(md protoreflect.MethodDescriptor, header map[string][]string, request map[string]any) bool {
+	if s.md.FullName() != md.FullName() {
+		return false
+	}
+	for k, v := range s.Metadata {
+		var found bool
+		for _, got := range header[strings.ToLower(k)] {
+			found = found || got == v
+		}
+		if !found {
+			return false
+		}
+	}
+	return containsFields(request, s.request)
+}
+
+// containsFields reports whether got has the fields of want, and the same
+// for nested messages.
+func containsFields(got, want map[string]any) bool {
+	for k, v := range want {
+		nested, ok := v.(map[string]any)
+		if ok {
+			gotNested, _ := got[k].(map[string]any)
+			if !containsFields(gotNested, nested) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got[k], v) {
+			return false
+		}
+	}
+	return true
+}
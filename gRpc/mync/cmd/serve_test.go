@@ -0,0 +1,423 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const testStubs = `
+http:
+  - method: GET
+    path: /users/*
+    headers:
+      Authorization: Bearer secret
+    response:
+      body: {id: "1", name: jane}
+  - method: GET
+    path: /users/*
+    response:
+      status: 401
+      body: unauthorized
+  - path: /slow
+    response:
+      status: 204
+      delay: 500ms
+grpc:
+  - method: Users/GetUser
+    request: {email: jane@doe.com}
+    metadata:
+      x-tenant: acme
+    response: {user: {id: "1", first_name: jane}}
+  - method: Users/GetUser
+    request: {email: jane@doe.com}
+    error: {code: PERMISSION_DENIED, message: wrong tenant}
+  - method: Users/GetUser
+    delay: 20ms
+    error: {code: NotFound, message: no such user}
+`
+
+// startStubServer serves stubs on local listeners until the test ends.
+func startStubServer(t *testing.T, stubs string, files *protoregistry.Files) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stubs.yaml")
+	err := os.WriteFile(path, []byte(stubs), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files == nil {
+		files, err = localRegistry()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := loadStubs(path, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var listeners []net.Listener
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners = append(listeners, l)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		server := &stubServer{stubs: s, files: files, logger: log.New(io.Discard, "", 0)}
+		done <- server.serve(ctx, listeners[0], listeners[1])
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	return listeners[0].Addr().String(), listeners[1].Addr().String()
+}
+
+func dialStubServer(t *testing.T, addr string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServeHttpStubs(t *testing.T) {
+	httpAddr, _ := startStubServer(t, testStubs, nil)
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			args:   []string{"-include", "-header", "Authorization=Bearer secret", "http://" + httpAddr + "/users/1"},
+			output: "HTTP/1.1 200 OK\nContent-Length: 24\nContent-Type: application/json\n",
+		},
+		{
+			name:   "test2",
+			args:   []string{"http://" + httpAddr + "/users/1"},
+			output: "unauthorized\n",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-verb", "DELETE", "-fail", "http://" + httpAddr + "/users/1"},
+			output: "no stub matches DELETE /users/1\n",
+			errMsg: "server responded with 404 Not Found",
+		},
+		{
+			name:   "test4",
+			args:   []string{"-timeout", "100ms", "http://" + httpAddr + "/slow"},
+			errMsg: "context deadline exceeded",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			err := HandleHttp(w, tc.args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error: %q, got: %v", tc.errMsg, err)
+			}
+			if !strings.HasPrefix(w.String(), tc.output) {
+				t.Errorf("Expected output to start with %q, got: %q", tc.output, w.String())
+			}
+		})
+	}
+}
+
+func TestServeGrpcStubs(t *testing.T) {
+	_, grpcAddr := startStubServer(t, testStubs, nil)
+	conn := dialStubServer(t, grpcAddr)
+
+	tests := []struct {
+		name   string
+		c      grpcConfig
+		output string
+		code   codes.Code
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			c:      grpcConfig{service: "Users", method: "GetUser", request: `{"email":"jane@doe.com"}`, metadata: []string{"x-tenant=acme"}},
+			output: `{"user":{"id":"1","firstName":"jane"}}`,
+		},
+		{
+			name:   "test2",
+			c:      grpcConfig{service: "Users", method: "GetUser", request: `{"email":"jane@doe.com","id":"2"}`, metadata: []string{"x-tenant=other"}},
+			code:   codes.PermissionDenied,
+			errMsg: "wrong tenant",
+		},
+		{
+			name:   "test3",
+			c:      grpcConfig{service: "Users", method: "GetUser", request: `{"email":"john@doe.com"}`},
+			code:   codes.NotFound,
+			errMsg: "no such user",
+		},
+		{
+			name:   "test4",
+			c:      grpcConfig{service: "Users", method: "GetUser", request: `{"email":"john@doe.com"}`, deadline: 10 * time.Millisecond},
+			code:   codes.DeadlineExceeded,
+			errMsg: "context deadline exceeded",
+		},
+		{
+			name:   "test5",
+			c:      grpcConfig{service: "Repo", method: "GetRepos", request: `{}`},
+			code:   codes.Unimplemented,
+			errMsg: "no stub matches /Repo/GetRepos",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			md, err := parseMetadata(tc.c.metadata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := new(bytes.Buffer)
+			err = callMethod(metadata.NewOutgoingContext(context.Background(), md), conn, tc.c, w)
+			if status.Code(err) != tc.code || (err != nil && !strings.Contains(err.Error(), tc.errMsg)) {
+				t.Fatalf("Expected %s error %q, got: %v", tc.code, tc.errMsg, err)
+			}
+			if diff := cmp.Diff(tc.output, strings.TrimSpace(strings.ReplaceAll(w.String(), " ", ""))); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServeStreamingStubs(t *testing.T) {
+	files, err := streamServiceFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+		return true
+	})
+	data, err := proto.Marshal(&set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protoset := filepath.Join(t.TempDir(), "stream.protoset")
+	err = os.WriteFile(protoset, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err = readProtoset(protoset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, grpcAddr := startStubServer(t, `
+grpc:
+  - method: Repo/GetRepos
+    request: {creatorId: user-1}
+    responses:
+      - {repo: {name: repo-1}}
+      - {repo: {name: repo-2}}
+    interval: 10ms
+    error: {code: 14, message: backend went away}
+  - method: Repo/CreateRepo
+    request: {context: {name: mync}}
+    response: {repo: {name: mync}, size: 6}
+  - method: Users/GetHelp
+    responses:
+      - {response: ask again}
+`, files)
+	conn := dialStubServer(t, grpcAddr)
+
+	tests := []struct {
+		name    string
+		c       grpcConfig
+		input   string
+		output  string
+		summary string
+	}{
+		{
+			name:    "test1",
+			c:       grpcConfig{service: "Repo", method: "GetRepos", request: `{"creatorId":"user-1"}`},
+			output:  "{\"repo\":{\"name\":\"repo-1\"}}\n{\"repo\":{\"name\":\"repo-2\"}}\n",
+			summary: "status=Unavailable sent=1 received=2\n",
+		},
+		{
+			name:    "test2",
+			c:       grpcConfig{service: "Repo", method: "CreateRepo"},
+			input:   "{\"context\":{\"name\":\"mync\"}}\n{\"data\":\"aGVsbG8=\"}\n",
+			output:  "{\"repo\":{\"name\":\"mync\"},\"size\":6}\n",
+			summary: "status=OK sent=2 received=1\n",
+		},
+		{
+			name:    "test3",
+			c:       grpcConfig{service: "Users", method: "GetHelp"},
+			input:   "{\"request\":\"help\"}\n{\"request\":\"more help\"}\n",
+			output:  "{\"response\":\"askagain\"}\n",
+			summary: "status=OK sent=2 received=1\n",
+		},
+	}
+
+	origStdin, origStderr := stdin, stderr
+	defer func() { stdin, stderr = origStdin, origStderr }()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			summary := new(bytes.Buffer)
+			stdin, stderr = strings.NewReader(tc.input), summary
+
+			callMethod(context.Background(), conn, tc.c, w)
+			if diff := cmp.Diff(tc.output, strings.ReplaceAll(w.String(), " ", "")); diff != "" {
+				t.Errorf("Unexpected output (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.summary, summary.String()); diff != "" {
+				t.Errorf("Unexpected summary (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadStubs(t *testing.T) {
+	files, err := localRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		stubs  string
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			stubs:  "http: [",
+			errMsg: "invalid stubs file: yaml",
+		},
+		{
+			name:   "test2",
+			stubs:  "http:\n  - response: {status: 200}\n",
+			errMsg: "invalid stubs file: http stub 1: path is missing",
+		},
+		{
+			name:   "test3",
+			stubs:  "grpc:\n  - method: Users.GetUser\n",
+			errMsg: `invalid stubs file: grpc stub 1: method "Users.GetUser" must be Service/Method`,
+		},
+		{
+			name:   "test4",
+			stubs:  "grpc:\n  - method: Users/ListUsers\n",
+			errMsg: "invalid stubs file: grpc stub 1: Invalid gRPC method Users/ListUsers",
+		},
+		{
+			name:   "test5",
+			stubs:  "grpc:\n  - method: Users/GetUser\n    responses: [{}, {}]\n",
+			errMsg: "invalid stubs file: grpc stub 1: Users/GetUser is not server streaming",
+		},
+		{
+			name:   "test6",
+			stubs:  "grpc:\n  - method: Users/GetUser\n    response: {name: jane}\n",
+			errMsg: "invalid stubs file: grpc stub 1: response: proto",
+		},
+		{
+			name:   "test7",
+			stubs:  "grpc:\n  - method: Users/GetUser\n    error: {code: Broken}\n",
+			errMsg: `invalid stubs file: grpc stub 1: unknown status code "Broken"`,
+		},
+		{
+			name:   "test8",
+			stubs:  "grpc:\n  - method: Repo/GetRepos\n",
+			errMsg: "invalid stubs file: grpc stub 1: a response or an error is missing",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "stubs.yaml")
+			err := os.WriteFile(path, []byte(tc.stubs), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = loadStubs(path, files)
+			if !errors.Is(err, ErrInvalidStubs) || !strings.HasPrefix(err.Error(), tc.errMsg) {
+				t.Errorf("Expected error: %q, got: %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+// TestLoadStubsExample loads the example in the doc comment of stubFile.
+func TestLoadStubsExample(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "serveStubs.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var example []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		decl, ok := n.(*ast.GenDecl)
+		if !ok || decl.Doc == nil || len(decl.Specs) != 1 {
+			return true
+		}
+		if spec, ok := decl.Specs[0].(*ast.TypeSpec); ok && spec.Name.Name == "stubFile" {
+			for _, line := range strings.Split(decl.Doc.Text(), "\n") {
+				if code, ok := strings.CutPrefix(line, "\t"); ok {
+					example = append(example, code)
+				}
+			}
+		}
+		return false
+	})
+	if len(example) == 0 {
+		t.Fatal("Expected an example in the doc comment of stubFile")
+	}
+
+	path := filepath.Join(t.TempDir(), "stubs.yaml")
+	err = os.WriteFile(path, []byte(strings.Join(example, "\n")), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := localRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stubs, err := loadStubs(path, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stubs.HTTP) != 1 || len(stubs.Grpc) != 2 {
+		t.Errorf("Expected 1 HTTP and 2 gRPC stubs, got %d and %d", len(stubs.HTTP), len(stubs.Grpc))
+	}
+}
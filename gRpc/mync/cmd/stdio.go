@@ -0,0 +1,12 @@
+package cmd
+
+import (
+	"io"
+	"os"
+)
+
+// stdin and stderr are variables so that tests can replace them.
+var (
+	stdin  io.Reader = os.Stdin
+	stderr io.Writer = os.Stderr
+)
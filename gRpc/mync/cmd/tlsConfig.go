@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tlsOptions holds the TLS flags shared by the http and grpc sub-commands.
+type tlsOptions struct {
+	caCert             string
+	cert               string
+	key                string
+	insecureSkipVerify bool
+	serverName         string
+}
+
+func addTLSFlags(fs *flag.FlagSet, t *tlsOptions) {
+	fs.StringVar(&t.caCert, "cacert", "", "CA certificate file (PEM) used to verify the server")
+	fs.StringVar(&t.cert, "cert", "", "Client certificate file (PEM) for mutual TLS")
+	fs.StringVar(&t.key, "key", "", "Client private key file (PEM) for mutual TLS")
+	fs.BoolVar(&t.insecureSkipVerify, "insecure-skip-verify", false, "Do not verify the server's TLS certificate")
+	fs.StringVar(&t.serverName, "server-name", "", "Name to verify the server's TLS certificate against")
+}
+
+// enabled reports whether any TLS option was given. gRPC connections are
+// only secured when it is true, HTTP follows the URL scheme.
+func (t tlsOptions) enabled() bool {
+	return len(t.caCert) != 0 || len(t.cert) != 0 || len(t.key) != 0 ||
+		t.insecureSkipVerify || len(t.serverName) != 0
+}
+
+func validateTLSOptions(t tlsOptions) error {
+	if (len(t.cert) == 0) != (len(t.key) == 0) {
+		return ErrInvalidTLSKeyPair
+	}
+	return nil
+}
+
+func (t tlsOptions) config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: t.insecureSkipVerify,
+		ServerName:         t.serverName,
+	}
+
+	if len(t.caCert) != 0 {
+		data, err := os.ReadFile(t.caCert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", t.caCert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if len(t.cert) != 0 {
+		cert, err := tls.LoadX509KeyPair(t.cert, t.key)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// tlsError adds a hint to errors caused by a failed TLS handshake. gRPC
+// flattens them into a status message, so they are matched by text.
+func tlsError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var hint string
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "certificate signed by unknown authority"):
+		hint = "use -cacert to trust the CA that signed the server certificate"
+	case strings.Contains(msg, "certificate is valid for"),
+		strings.Contains(msg, "certificate is not valid for any names"),
+		strings.Contains(msg, "doesn't contain any IP SANs"):
+		hint = "use -server-name to verify the certificate against another name"
+	case strings.Contains(msg, "certificate has expired or is not yet valid"):
+		hint = "the server certificate has expired or is not valid yet"
+	case strings.Contains(msg, "tls: certificate required"),
+		strings.Contains(msg, "tls: bad certificate"):
+		hint = "the server requires a client certificate it trusts, use -cert and -key"
+	default:
+		return err
+	}
+	return fmt.Errorf("TLS handshake failed: %w (%s)", err, hint)
+}
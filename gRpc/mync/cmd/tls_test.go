@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	svc "service"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+type testCerts struct {
+	caFile     string
+	certFile   string
+	keyFile    string
+	pool       *x509.CertPool
+	serverCert tls.Certificate
+}
+
+func writePem(t *testing.T, path, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	err := os.WriteFile(path, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// generateTestCerts creates a throwaway CA, a server certificate valid for
+// mync.test only and a client certificate signed by the CA.
+func generateTestCerts(t *testing.T) testCerts {
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mync test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := testCerts{
+		caFile:   filepath.Join(dir, "ca.pem"),
+		certFile: filepath.Join(dir, "client.pem"),
+		keyFile:  filepath.Join(dir, "client-key.pem"),
+		pool:     x509.NewCertPool(),
+	}
+	writePem(t, certs.caFile, "CERTIFICATE", caDer)
+	certs.pool.AddCert(caCert)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage, dnsNames []string) ([]byte, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "mync test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			DNSNames:     dnsNames,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der, key
+	}
+
+	serverDer, serverKey := issue(2, x509.ExtKeyUsageServerAuth, []string{"mync.test"})
+	certs.serverCert = tls.Certificate{Certificate: [][]byte{serverDer}, PrivateKey: serverKey}
+
+	clientDer, clientKey := issue(3, x509.ExtKeyUsageClientAuth, nil)
+	writePem(t, certs.certFile, "CERTIFICATE", clientDer)
+	keyDer, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePem(t, certs.keyFile, "EC PRIVATE KEY", keyDer)
+	return certs
+}
+
+func (c testCerts) serverConfig(requireClientCert bool) *tls.Config {
+	cfg := &tls.Config{Certificates: []tls.Certificate{c.serverCert}}
+	if requireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = c.pool
+	}
+	return cfg
+}
+
+func TestHandleHttpTLS(t *testing.T) {
+	certs := generateTestCerts(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "this is a TLS response")
+	})
+
+	ts := httptest.NewUnstartedServer(handler)
+	ts.TLS = certs.serverConfig(false)
+	ts.StartTLS()
+	defer ts.Close()
+
+	mts := httptest.NewUnstartedServer(handler)
+	mts.TLS = certs.serverConfig(true)
+	mts.StartTLS()
+	defer mts.Close()
+
+	tests := []struct {
+		name   string
+		args   []string
+		output string
+		errMsg string
+	}{
+		{
+			name:   "test1",
+			args:   []string{"-server-name", "mync.test", ts.URL},
+			errMsg: "use -cacert",
+		},
+		{
+			name:   "test2",
+			args:   []string{"-cacert", certs.caFile, ts.URL},
+			errMsg: "use -server-name",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-cacert", certs.caFile, "-server-name", "mync.test", ts.URL},
+			output: "this is a TLS response\n",
+		},
+		{
+			name:   "test4",
+			args:   []string{"-insecure-skip-verify", ts.URL},
+			output: "this is a TLS response\n",
+		},
+		{
+			name:   "test5",
+			args:   []string{"-cacert", certs.caFile, "-server-name", "mync.test", mts.URL},
+			errMsg: "use -cert and -key",
+		},
+		{
+			name:   "test6",
+			args:   []string{"-cacert", certs.caFile, "-server-name", "mync.test", "-cert", certs.certFile, "-key", certs.keyFile, mts.URL},
+			output: "this is a TLS response\n",
+		},
+		{
+			name:   "test7",
+			args:   []string{"-cert", certs.certFile, mts.URL},
+			errMsg: ErrInvalidTLSKeyPair.Error(),
+		},
+		{
+			name:   "test8",
+			args:   []string{"-cacert", certs.keyFile, ts.URL},
+			errMsg: "no PEM certificates found in " + certs.keyFile,
+		},
+	}
+
+	w := new(bytes.Buffer)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := HandleHttp(w, tc.args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if len(tc.output) != 0 && w.String() != tc.output {
+				t.Errorf("Expected output: %q, got: %q", tc.output, w.String())
+			}
+		})
+		w.Reset()
+	}
+}
+
+func TestHandleGrpcTLS(t *testing.T) {
+	certs := generateTestCerts(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(certs.serverConfig(true))))
+	defer s.Stop()
+	svc.RegisterUsersServer(s, &dummyUserService{})
+	go func() {
+		s.Serve(l)
+	}()
+
+	tests := []struct {
+		name     string
+		args     []string
+		errMsg   string
+		respJson string
+	}{
+		{
+			name:   "test1",
+			args:   []string{"-service", "Users", "-method", "GetUser", l.Addr().String()},
+			errMsg: "Unavailable",
+		},
+		{
+			name:   "test2",
+			args:   []string{"-insecure-skip-verify", "-service", "Users", "-method", "GetUser", l.Addr().String()},
+			errMsg: "use -cert and -key",
+		},
+		{
+			name:   "test3",
+			args:   []string{"-cert", certs.certFile, "-key", certs.keyFile, "-server-name", "mync.test", "-service", "Users", "-method", "GetUser", l.Addr().String()},
+			errMsg: "use -cacert",
+		},
+		{
+			name:   "test4",
+			args:   []string{"-cacert", certs.caFile, "-cert", certs.certFile, "-key", certs.keyFile, "-service", "Users", "-method", "GetUser", l.Addr().String()},
+			errMsg: "use -server-name",
+		},
+		{
+			name: "test5",
+			args: []string{
+				"-cacert", certs.caFile, "-cert", certs.certFile, "-key", certs.keyFile, "-server-name", "mync.test",
+				"-service", "Users", "-method", "GetUser", "-request", `{"email":"john@doe.com","id":"user-123"}`, l.Addr().String(),
+			},
+			respJson: `{"user":{"id":"user-123","firstName":"john","lastName":"doe.com","age":36}}`,
+		},
+		{
+			name:   "test6",
+			args:   []string{"-key", certs.keyFile, "-service", "Users", "-method", "GetUser", l.Addr().String()},
+			errMsg: ErrInvalidTLSKeyPair.Error(),
+		},
+	}
+
+	w := new(bytes.Buffer)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := HandleGrpc(w, tc.args)
+			var errMsg string
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if !strings.Contains(errMsg, tc.errMsg) || (len(tc.errMsg) == 0 && err != nil) {
+				t.Fatalf("Expected error: %v, got: %v", tc.errMsg, errMsg)
+			}
+			if len(tc.respJson) != 0 {
+				sanitizedRespJson := strings.Replace(w.String(), " ", "", -1)
+				sanitizedRespJson = strings.Replace(sanitizedRespJson, "\n", "", -1)
+				if sanitizedRespJson != tc.respJson {
+					t.Errorf("Expected result: %v Got: %v", tc.respJson, sanitizedRespJson)
+				}
+			}
+		})
+		w.Reset()
+	}
+}
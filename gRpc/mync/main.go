@@ -11,9 +11,12 @@ import (
 var errInvalidSubCommand = errors.New("invalid sub-command specified")
 
 func printUsage(w io.Writer) {
-	fmt.Fprint(w, "Usage: mync [http|grpc] -h\n")
+	fmt.Fprint(w, "Usage: mync [http|grpc|serve|run|completion] -h\n")
 	cmd.HandleHttp(w, []string{"-h"})
 	cmd.HandleGrpc(w, []string{"-h"})
+	cmd.HandleServe(w, []string{"-h"})
+	cmd.HandleRun(w, []string{"-h"})
+	cmd.HandleCompletion(w, []string{"-h"})
 }
 
 func handleCommand(w io.Writer, args []string) error {
@@ -27,6 +30,15 @@ func handleCommand(w io.Writer, args []string) error {
 			err = cmd.HandleHttp(w, args[1:])
 		case "grpc":
 			err = cmd.HandleGrpc(w, args[1:])
+		case "serve":
+			err = cmd.HandleServe(w, args[1:])
+		case "run":
+			err = cmd.HandleRun(w, args[1:])
+		case "completion":
+			err = cmd.HandleCompletion(w, args[1:])
+		case "__complete":
+			// Called by the completion scripts, see mync completion.
+			err = cmd.HandleComplete(w, args[1:])
 		case "-h", "-help":
 			printUsage(w)
 		default:
@@ -48,6 +60,6 @@ func handleCommand(w io.Writer, args []string) error {
 func main() {
 	err := handleCommand(os.Stdout, os.Args[1:])
 	if err != nil {
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
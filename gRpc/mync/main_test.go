@@ -21,47 +21,194 @@ import (
 )
 
 func Test_handleCommnd(t *testing.T) {
-	usageMessage := `Usage: mync [http|grpc] -h
+	usageMessage := `Usage: mync [http|grpc|serve|run|completion] -h
 
 http: A HTTP client.
  
 http: <options> server
+http: replay <options> file.har
 
 Options: 
   -basicAuth string
     	Add basic auth (username:password) credentials to the outgoing request
+  -bench
+    	Load test the server and print a summary instead of the responses
+  -bench-format string
+    	Format of the bench mode summary (text or json) (default "text")
   -body string
-    	JSON data for HTTP POST request
+    	Request body, sent as JSON unless -content-type is set
   -body-file string
-    	File containing JSON data for HTTP POST request
+    	File containing the request body (- for stdin)
+  -cacert string
+    	CA certificate file (PEM) used to verify the server
+  -cert string
+    	Client certificate file (PEM) for mutual TLS
+  -concurrency int
+    	Number of concurrent requests in bench mode (default 1)
+  -content-type string
+    	Content type of the request body, JSON or form-urlencoded by default
+  -cookie-jar string
+    	File to load cookies from and save them to (Netscape cookies.txt format)
+  -data-urlencode value
+    	Add a field to a form-urlencoded request body (key=value)
   -disable-redirect
     	Do not follow redirection request
+  -duration duration
+    	Duration of the load test in bench mode, overrides num-requests unless it is also set
+  -fail
+    	Exit with an error if the server responds with a 4xx or 5xx status
+  -file value
+    	Add a file to a multipart/form-data request body (field=@path)
+  -form value
+    	Add a field to a multipart/form-data request body (name=value)
+  -from-curl string
+    	curl command to take the request from, overridden by flags
+  -har string
+    	File to record the requests and responses into (HAR 1.2 format)
   -header value
     	Add one or more headers to the outgoing request (key=value)
+  -include
+    	Print the response status line and headers before the body
+  -insecure-skip-verify
+    	Do not verify the server's TLS certificate
+  -key string
+    	Client private key file (PEM) for mutual TLS
   -max-idle-conns int
     	Maximum number of idle connections for the connection pool
   -num-requests int
     	Number of requests to make (default 1)
   -output string
     	File path to write the response into
+  -parallel int
+    	Number of byte ranges to download concurrently with -output (default 1)
+  -pretty-print
+    	Pretty print JSON response bodies
+  -print-curl
+    	Print the request as a curl command instead of sending it
+  -profile string
+    	Named profile in the config file to take defaults from, overridden by flags
+  -proxy string
+    	Proxy URL (http, https or socks5), instead of the proxy of the environment
+  -query string
+    	Print the values selected from the JSON response body, e.g. .repo[0].owner.id
+  -rate float
+    	Maximum number of requests per second in bench mode (0 for no limit)
   -report
     	report this http request's latency
+  -report-format string
+    	Format of the latency report (text or json) (default "text")
+  -resolve value
+    	Connect to addr instead of host:port, e.g. example.com:443:127.0.0.1 (host:port:addr)
+  -retries int
+    	Number of times to retry a failed request
+  -retry-backoff duration
+    	Delay before the first retry, doubled for every further retry (default 100ms)
+  -retry-on string
+    	Comma separated status codes (e.g. 503 or 5xx) and connection errors to retry on (default "5xx,429,connection")
+  -server-name string
+    	Name to verify the server's TLS certificate against
+  -sha256 string
+    	Expected SHA-256 digest (hex) of the file downloaded with -output
+  -timeout duration
+    	Timeout of each request, including reading the response unless it is saved with -output (default 200ms)
+  -unix-socket string
+    	Unix socket to connect to instead of the host of the URL
   -verb string
     	HTTP method (default "GET")
+  -write-out string
+    	Template printed after each response, e.g. '{{.StatusCode}} {{.Latency}}'
 
 grpc: A gRPC client.
  
 grpc: <options> server
+grpc: list <options> server
+grpc: describe <options> server [symbol]
 
 Options:
+  -bench
+    	Load test the server and print a summary instead of the responses
+  -bench-format string
+    	Format of the bench mode summary (text or json) (default "text")
+  -cacert string
+    	CA certificate file (PEM) used to verify the server
+  -cert string
+    	Client certificate file (PEM) for mutual TLS
+  -concurrency int
+    	Number of concurrent calls in bench mode or for unary methods with -request-file (default 1)
+  -connections int
+    	Number of connections to spread the calls over in bench mode (default 1)
+  -deadline duration
+    	Deadline of each call, e.g. 5s (no deadline by default)
+  -duration duration
+    	Duration of the load test in bench mode, overrides num-calls unless it is also set
+  -error-format string
+    	Format of errors with a gRPC status (text or json) (default "text")
+  -insecure-skip-verify
+    	Do not verify the server's TLS certificate
+  -key string
+    	Client private key file (PEM) for mutual TLS
+  -metadata value
+    	Add one or more metadata entries to the call (key=value, base64 values for -bin keys)
   -method string
     	Method to call
+  -num-calls int
+    	Number of calls to make in bench mode (default 1)
   -pretty-print
     	Pretty print the JSON output
+  -profile string
+    	Named profile in the config file to take defaults from, overridden by flags
+  -query string
+    	Print the values selected from each JSON response, e.g. .repo[0].owner.id
+  -rate float
+    	Maximum number of calls per second in bench mode (0 for no limit)
   -request string
     	Request to send
+  -request-file string
+    	File with one JSON request per line (- for stdin), sent as a stream or as one unary call each
+  -server-name string
+    	Name to verify the server's TLS certificate against
   -service string
     	gRpc service to send the request to
+  -verbose
+    	Print the request metadata, response headers and trailers and the status on stderr
+
+Exit codes:
+  0     The call succeeded
+  1     Invalid usage or an error without a gRPC status
+  10+N  The call failed with gRPC status code N:
+        11 Canceled, 12 Unknown, 13 InvalidArgument, 14 DeadlineExceeded,
+        15 NotFound, 16 AlreadyExists, 17 PermissionDenied,
+        18 ResourceExhausted, 19 FailedPrecondition, 20 Aborted,
+        21 OutOfRange, 22 Unimplemented, 23 Internal, 24 Unavailable,
+        25 DataLoss, 26 Unauthenticated
+
+serve: A mock HTTP and gRPC server.
+
+serve: -stubs stubs.yaml <options>
+
+Options: 
+  -grpc-addr string
+    	Address to serve the gRPC stubs on (default "localhost:50051")
+  -http-addr string
+    	Address to serve the HTTP stubs on (default "localhost:8080")
+  -protoset string
+    	File descriptor set to take the services from instead of the Users and Repo protos of mync
+  -stubs string
+    	YAML file with the HTTP and gRPC stubs to serve
+
+run: Run scenarios of HTTP and gRPC steps with assertions.
+
+run: <options> scenario.yaml...
+
+Options: 
+  -format string
+    	Format of the report (tap or junit) (default "tap")
+  -var value
+    	Set a variable of the scenarios, overriding their vars (key=value)
+
+completion: Shell completion for mync.
+
+completion: bash|zsh|fish
 `
 	tests := []struct {
 		name   string
@@ -234,6 +381,13 @@ func TestSubCommandInvoke(t *testing.T) {
 			},
 			exitCode: 1,
 		},
+		{
+			name:     "test8",
+			args:     []string{"grpc", "-service", "Users", "-method", "GetUser", "-deadline", "100ms", "-error-format", "json", testGrpcServerURL},
+			input:    "",
+			output:   []string{`{"code":"Unimplemented","codeNumber":12,"message":"unknown service Users","exitCode":22}`},
+			exitCode: 22,
+		},
 	}
 
 	w := new(bytes.Buffer)
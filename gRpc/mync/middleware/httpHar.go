@@ -0,0 +1,361 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Har is an HTTP Archive in the HAR 1.2 format, see
+// http://www.softwareishard.com/blog/har-12-spec/.
+type Har struct {
+	Log HarLog `json:"log"`
+}
+
+type HarLog struct {
+	Version string     `json:"version"`
+	Creator HarCreator `json:"creator"`
+	Entries []HarEntry `json:"entries"`
+}
+
+type HarCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HarEntry is a single request and its response. A request that failed
+// without a response has a zero Response and the error in Comment.
+type HarEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HarRequest  `json:"request"`
+	Response        HarResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HarTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HarNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HarRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarNameValue `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	QueryString []HarNameValue `json:"queryString"`
+	PostData    *HarPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HarPostData is a request body. Bodies that are not valid UTF-8 are base64
+// encoded, which HAR 1.2 only provides for response content, so the
+// encoding is recorded in the custom _encoding field.
+type HarPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HarResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarNameValue `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	Content     HarContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HarContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HarTimings are in milliseconds, -1 for phases that did not happen.
+type HarTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HarText returns the body as HAR text, base64 encoded if it is not UTF-8.
+func HarText(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// HarDecode returns the body of HAR text.
+func HarDecode(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+// HttpHarRecorder records every request sent through it, including each
+// redirect and retry, with the response once its body is closed. Bodies
+// are recorded up to MaxBodySize bytes.
+type HttpHarRecorder struct {
+	Transport   http.RoundTripper
+	MaxBodySize int64
+
+	mu      sync.Mutex
+	entries []HarEntry
+}
+
+// Har returns the entries recorded so far, in the order they were sent.
+func (h *HttpHarRecorder) Har(creator HarCreator) Har {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := append([]HarEntry{}, h.entries...)
+	sort.SliceStable(entries, func(a, b int) bool {
+		return entries[a].StartedDateTime.Before(entries[b].StartedDateTime)
+	})
+	return Har{Log: HarLog{Version: "1.2", Creator: creator, Entries: entries}}
+}
+
+func (h *HttpHarRecorder) add(e HarEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, e)
+}
+
+func harHeaders(header http.Header) []HarNameValue {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	nv := []HarNameValue{}
+	for _, k := range keys {
+		for _, v := range header[k] {
+			nv = append(nv, HarNameValue{Name: k, Value: v})
+		}
+	}
+	return nv
+}
+
+func harCookies(cookies []*http.Cookie) []HarNameValue {
+	nv := []HarNameValue{}
+	for _, c := range cookies {
+		nv = append(nv, HarNameValue{Name: c.Name, Value: c.Value})
+	}
+	return nv
+}
+
+// limitedBody keeps the first max bytes written to it and counts the rest.
+type limitedBody struct {
+	buf  bytes.Buffer
+	max  int64
+	size int64
+}
+
+func (b *limitedBody) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if room := b.max - int64(b.buf.Len()); room > 0 {
+		b.buf.Write(p[:min(int64(len(p)), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBody) truncated() bool {
+	return b.size > int64(b.buf.Len())
+}
+
+// requestBody reads a copy of the request body, leaving r able to send it.
+func (h *HttpHarRecorder) requestBody(r *http.Request) (*HarPostData, int64, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, 0, nil
+	}
+	var body io.ReadCloser
+	if r.GetBody != nil {
+		var err error
+		body, err = r.GetBody()
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		data, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		body = io.NopCloser(bytes.NewReader(data))
+	}
+	defer body.Close()
+
+	b := &limitedBody{max: h.MaxBodySize}
+	_, err := io.Copy(b, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	pd := &HarPostData{MimeType: r.Header.Get("Content-Type")}
+	pd.Text, pd.Encoding = HarText(b.buf.Bytes())
+	if b.truncated() {
+		pd.Comment = "truncated"
+	}
+	return pd, b.size, nil
+}
+
+func (h *HttpHarRecorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt := &requestTrace{start: time.Now()}
+	r = r.WithContext(httptrace.WithClientTrace(r.Context(), rt.clientTrace()))
+
+	entry := HarEntry{
+		StartedDateTime: rt.start,
+		Request: HarRequest{
+			Method:      r.Method,
+			URL:         r.URL.String(),
+			HTTPVersion: r.Proto,
+			Cookies:     harCookies(r.Cookies()),
+			Headers:     harHeaders(r.Header),
+			QueryString: []HarNameValue{},
+			HeadersSize: -1,
+		},
+	}
+	host := r.Host
+	if len(host) == 0 {
+		host = r.URL.Host
+	}
+	entry.Request.Headers = append([]HarNameValue{{Name: "Host", Value: host}}, entry.Request.Headers...)
+	for k, values := range r.URL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, HarNameValue{Name: k, Value: v})
+		}
+	}
+	sort.SliceStable(entry.Request.QueryString, func(a, b int) bool {
+		return entry.Request.QueryString[a].Name < entry.Request.QueryString[b].Name
+	})
+	postData, size, err := h.requestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	entry.Request.PostData, entry.Request.BodySize = postData, size
+
+	resp, err := h.Transport.RoundTrip(r)
+	if err != nil {
+		entry.Comment = err.Error()
+		entry.Response = HarResponse{Cookies: []HarNameValue{}, Headers: []HarNameValue{}, HeadersSize: -1, BodySize: -1}
+		h.finish(rt, entry, nil)
+		return nil, err
+	}
+
+	entry.Response = HarResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies()),
+		Headers:     harHeaders(resp.Header),
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	body := &limitedBody{max: h.MaxBodySize}
+	resp.Body = &recordOnClose{
+		ReadCloser: resp.Body,
+		body:       body,
+		record: func() {
+			h.finish(rt, entry, func(e *HarEntry) {
+				content := HarContent{Size: body.size, MimeType: resp.Header.Get("Content-Type")}
+				content.Text, content.Encoding = HarText(body.buf.Bytes())
+				if body.truncated() {
+					content.Comment = "truncated"
+				}
+				e.Response.Content = content
+				e.Response.BodySize = body.size
+				if resp.Uncompressed {
+					// The size on the wire is not known.
+					e.Response.BodySize = -1
+				}
+			})
+		},
+	}
+	return resp, nil
+}
+
+// finish completes the timings of entry and records it.
+func (h *HttpHarRecorder) finish(rt *requestTrace, entry HarEntry, complete func(*HarEntry)) {
+	timing := rt.done()
+	rt.mu.Lock()
+	gotConn, wrote, firstByte := rt.gotConn, rt.wroteRequest, rt.firstByte
+	rt.mu.Unlock()
+
+	ms := func(d time.Duration) float64 {
+		return milliseconds(d)
+	}
+	between := func(from, to time.Time) float64 {
+		if from.IsZero() || to.IsZero() {
+			return -1
+		}
+		return ms(to.Sub(from))
+	}
+	t := HarTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if !timing.ConnReused {
+		if timing.DNS > 0 {
+			t.DNS = ms(timing.DNS)
+		}
+		if timing.Connect > 0 {
+			// HAR counts the TLS handshake as part of connecting.
+			t.Connect = ms(timing.Connect + timing.TLS)
+		}
+		if timing.TLS > 0 {
+			t.SSL = ms(timing.TLS)
+		}
+	}
+	t.Send = max(between(gotConn, wrote), 0)
+	t.Wait = max(between(wrote, firstByte), 0)
+	t.Receive = max(ms(timing.Transfer), 0)
+	if !gotConn.IsZero() {
+		t.Blocked = max(ms(gotConn.Sub(rt.start))-max(t.DNS, 0)-max(t.Connect, 0), 0)
+	}
+	entry.Timings = t
+	entry.Time = ms(timing.Total)
+	if complete != nil {
+		complete(&entry)
+	}
+	h.add(entry)
+}
+
+// recordOnClose copies the response body while it is read and records the
+// entry once, when the body is closed.
+type recordOnClose struct {
+	io.ReadCloser
+	body   *limitedBody
+	once   sync.Once
+	record func()
+}
+
+func (b *recordOnClose) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.body.Write(p[:n])
+	return n, err
+}
+
+func (b *recordOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.record)
+	return err
+}
@@ -1,22 +1,220 @@
 package middleware
 
 import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
 	"time"
 )
 
+// HttpLatencyClient logs the latency of every request once its response
+// body is closed, broken down into the phases reported by httptrace. The
+// breakdown is printed as text, or as a line of JSON if JSON is set.
 type HttpLatencyClient struct {
 	Logger    *log.Logger
 	Transport http.RoundTripper
+	JSON      bool
+}
+
+// HttpTiming is the phase breakdown of a single request. TTFB is measured
+// from when the connection was ready, so that it only covers the time the
+// server took to respond, and Transfer is the time spent reading the body.
+type HttpTiming struct {
+	URL        string        `json:"url"`
+	Method     string        `json:"method"`
+	Protocol   string        `json:"protocol"`
+	Status     int           `json:"status,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	DNS        time.Duration `json:"-"`
+	Connect    time.Duration `json:"-"`
+	TLS        time.Duration `json:"-"`
+	TTFB       time.Duration `json:"-"`
+	Transfer   time.Duration `json:"-"`
+	Total      time.Duration `json:"-"`
+	ConnReused bool          `json:"connReused"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (t HttpTiming) MarshalJSON() ([]byte, error) {
+	type timing HttpTiming
+	return json.Marshal(struct {
+		timing
+		DNS      float64 `json:"dnsMs"`
+		Connect  float64 `json:"connectMs"`
+		TLS      float64 `json:"tlsMs"`
+		TTFB     float64 `json:"ttfbMs"`
+		Transfer float64 `json:"transferMs"`
+		Total    float64 `json:"totalMs"`
+	}{
+		timing:   timing(t),
+		DNS:      milliseconds(t.DNS),
+		Connect:  milliseconds(t.Connect),
+		TLS:      milliseconds(t.TLS),
+		TTFB:     milliseconds(t.TTFB),
+		Transfer: milliseconds(t.Transfer),
+		Total:    milliseconds(t.Total),
+	})
+}
+
+func (t HttpTiming) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "url=%s method=%s protocol=%s latency=%f", t.URL, t.Method, t.Protocol, t.Total.Seconds())
+	if len(t.Error) != 0 {
+		fmt.Fprintf(&b, " error=%q", t.Error)
+	}
+	b.WriteString("\n")
+	for _, phase := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"DNS lookup", t.DNS},
+		{"TCP connect", t.Connect},
+		{"TLS handshake", t.TLS},
+		{"Time to first byte", t.TTFB},
+		{"Content transfer", t.Transfer},
+	} {
+		fmt.Fprintf(&b, "  %-20s%s\n", phase.name+":", phase.d)
+	}
+	fmt.Fprintf(&b, "  %-20s%t", "Connection reused:", t.ConnReused)
+	return b.String()
+}
+
+// requestTrace collects the httptrace events of a request, which may be
+// delivered from several goroutines.
+type requestTrace struct {
+	mu                     sync.Mutex
+	start                  time.Time
+	dnsStart, connectStart time.Time
+	tlsStart, gotConn      time.Time
+	wroteRequest           time.Time
+	firstByte              time.Time
+	timing                 HttpTiming
+}
+
+func (rt *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.timing.DNS = time.Since(rt.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			if rt.connectStart.IsZero() {
+				rt.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			if err == nil && rt.timing.Connect == 0 {
+				rt.timing.Connect = time.Since(rt.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.timing.TLS = time.Since(rt.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.gotConn = time.Now()
+			rt.timing.ConnReused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			rt.mu.Lock()
+			defer rt.mu.Unlock()
+			rt.firstByte = time.Now()
+			rt.timing.TTFB = rt.firstByte.Sub(rt.gotConn)
+		},
+	}
+}
+
+// done completes the timing once the response body was read or the
+// request failed.
+func (rt *requestTrace) done() HttpTiming {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	now := time.Now()
+	if !rt.firstByte.IsZero() {
+		rt.timing.Transfer = now.Sub(rt.firstByte)
+	}
+	rt.timing.Total = now.Sub(rt.start)
+	return rt.timing
 }
 
 func (c HttpLatencyClient) RoundTrip(r *http.Request) (*http.Response, error) {
-	startTime := time.Now()
+	rt := &requestTrace{
+		start:  time.Now(),
+		timing: HttpTiming{URL: r.URL.String(), Method: r.Method, Protocol: r.Proto},
+	}
+	r = r.WithContext(httptrace.WithClientTrace(r.Context(), rt.clientTrace()))
+
 	resp, err := c.Transport.RoundTrip(r)
-	c.Logger.Printf(
-		"url=%s method=%s protocol=%s latency=%f\n",
-		r.URL, r.Method, r.Proto, time.Since(startTime).Seconds(),
-	)
-	return resp, err
+	rt.mu.Lock()
+	if err != nil {
+		rt.timing.Error = err.Error()
+	} else {
+		rt.timing.Protocol = resp.Proto
+		rt.timing.Status = resp.StatusCode
+	}
+	rt.mu.Unlock()
+	if err != nil {
+		c.log(rt.done())
+		return nil, err
+	}
+	resp.Body = &logOnClose{ReadCloser: resp.Body, log: func() { c.log(rt.done()) }}
+	return resp, nil
+}
+
+func (c HttpLatencyClient) log(t HttpTiming) {
+	if !c.JSON {
+		c.Logger.Println(t)
+		return
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		c.Logger.Println(err)
+		return
+	}
+	fmt.Fprintln(c.Logger.Writer(), string(data))
+}
+
+// logOnClose logs the timing of a request once, when its body is closed.
+type logOnClose struct {
+	io.ReadCloser
+	once sync.Once
+	log  func()
+}
+
+func (b *logOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.log)
+	return err
 }
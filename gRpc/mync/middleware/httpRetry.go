@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HttpRetryClient retries requests for which RetryOn returns true, waiting
+// Backoff before the first retry and twice as long before every further
+// one, up to 5 minutes or Backoff if it is longer, unless the response asks
+// for a delay with a Retry-After header. A response asking for a longer
+// delay than that is returned instead of being retried.
+//
+// Each attempt gets its own Timeout, which also covers reading the body of
+// the response that is returned unless HeaderTimeout is set. Attempts are
+// logged when Logger is set.
+type HttpRetryClient struct {
+	Logger        *log.Logger
+	Transport     http.RoundTripper
+	Retries       int
+	Backoff       time.Duration
+	Timeout       time.Duration
+	HeaderTimeout bool
+	RetryOn       func(resp *http.Response, err error) bool
+}
+
+// ErrResponseHeaderTimeout is returned when a timeout limited to waiting
+// for the response headers expires.
+var ErrResponseHeaderTimeout = fmt.Errorf("%w awaiting the response headers", context.DeadlineExceeded)
+
+func (c HttpRetryClient) RoundTrip(r *http.Request) (*http.Response, error) {
+	// A request body can only be sent again if it can be recreated.
+	retries := c.Retries
+	if r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		req := r
+		if attempt > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = r.Clone(r.Context())
+			req.Body = body
+		}
+
+		var ctx context.Context
+		var cancel context.CancelFunc
+		var headerTimer *time.Timer
+		switch {
+		case c.Timeout > 0 && c.HeaderTimeout:
+			ctx, cancel = context.WithCancel(req.Context())
+			headerTimer = time.AfterFunc(c.Timeout, cancel)
+		case c.Timeout > 0:
+			ctx, cancel = context.WithTimeout(req.Context(), c.Timeout)
+		default:
+			ctx, cancel = context.WithCancel(req.Context())
+		}
+		resp, err := c.Transport.RoundTrip(req.WithContext(ctx))
+		if headerTimer != nil && !headerTimer.Stop() && r.Context().Err() == nil {
+			if err == nil {
+				resp.Body.Close()
+			}
+			resp, err = nil, ErrResponseHeaderTimeout
+		}
+
+		after, hasAfter := time.Duration(0), false
+		if err == nil {
+			after, hasAfter = retryAfter(resp)
+		}
+		tooLate := hasAfter && after > max(c.Backoff, maxRetryBackoff)
+
+		if attempt == retries || r.Context().Err() != nil || tooLate || !c.RetryOn(resp, err) {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		delay := c.backoff(attempt)
+		status := "none"
+		if resp != nil {
+			status = strconv.Itoa(resp.StatusCode)
+			if hasAfter {
+				delay = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+		if c.Logger != nil {
+			c.Logger.Printf(
+				"url=%s method=%s attempt=%d status=%s error=%v retry_in=%s\n",
+				r.URL, r.Method, attempt+1, status, err, delay,
+			)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+// maxRetryBackoff caps the doubling delay between retries, which would
+// otherwise overflow after enough attempts.
+const maxRetryBackoff = 5 * time.Minute
+
+// backoff returns the delay before retrying the given attempt.
+func (c HttpRetryClient) backoff(attempt int) time.Duration {
+	if c.Backoff <= 0 {
+		return 0
+	}
+	limit := max(c.Backoff, maxRetryBackoff)
+	if attempt >= 62 || c.Backoff > limit>>attempt {
+		return limit
+	}
+	return c.Backoff << attempt
+}
+
+// retryAfter returns the delay asked for by a Retry-After header, given
+// either in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		if seconds > math.MaxInt64/int64(time.Second) {
+			return math.MaxInt64, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
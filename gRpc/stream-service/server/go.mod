@@ -4,6 +4,7 @@ go 1.22.5
 
 require (
 	google.golang.org/grpc v1.66.2
+	google.golang.org/protobuf v1.34.2
 	service v0.0.0-00010101000000-000000000000
 )
 
@@ -12,7 +13,6 @@ require (
 	golang.org/x/sys v0.21.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240604185151-ef581f913117 // indirect
-	google.golang.org/protobuf v1.34.2 // indirect
 )
 
 replace service => ../service